@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security/loaders/priamo"
+)
+
+func main() {
+	var (
+		from = flag.Int("from", 300, "first code to check")
+		to   = flag.Int("to", 400, "last code to check")
+	)
+
+	flag.Parse()
+
+	comparti, err := priamo.Discover(*from, *to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ failed discovering comparti: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	if len(comparti) == 0 {
+		fmt.Printf("❌ no comparti found for codes %d-%d\n", *from, *to)
+		os.Exit(1)
+	}
+
+	for _, c := range comparti {
+		fmt.Printf("code=%s\tquotes=%d\tfrom=%s\tto=%s\tlast=%v\n",
+			c.Code, c.Quotes,
+			c.FirstDate.Format("2006-01-02"), c.LastDate.Format("2006-01-02"),
+			c.LastClose,
+		)
+	}
+}
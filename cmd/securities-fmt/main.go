@@ -8,6 +8,9 @@ import (
 	"os"
 	"sort"
 	"strings"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/loaders/borsaitaliana"
 )
 
 type Block struct {
@@ -102,9 +105,17 @@ func readBlocks(path string) ([]Block, error) {
 		default:
 			r := csv.NewReader(strings.NewReader(line))
 			rec, err := r.Read()
-			if err != nil || len(rec) != 3 {
+			if err != nil || len(rec) < 3 || len(rec) > 4 {
 				return nil, fmt.Errorf("invalid CSV line: %q", line)
 			}
+			if rec[2] == "borsaitaliana" {
+				if _, _, _, err := borsaitaliana.ParseKey(rec[0]); err != nil {
+					return nil, fmt.Errorf("invalid CSV line: %q: %w", line, err)
+				}
+			}
+			if err := checkBondCurrency(rec); err != nil {
+				return nil, fmt.Errorf("invalid CSV line: %q: %w", line, err)
+			}
 			cur.Rows = append(cur.Rows, rec)
 		}
 	}
@@ -113,6 +124,18 @@ func readBlocks(path string) ([]Block, error) {
 	return blocks, sc.Err()
 }
 
+// checkBondCurrency checks the 'currency' param of the bonds against their name
+func checkBondCurrency(rec []string) error {
+	params := security.Params{}
+	if len(rec) > 3 {
+		var err error
+		if params, err = security.ParseParams(rec[3]); err != nil {
+			return err
+		}
+	}
+	return security.CheckBondCurrency(rec[1], params)
+}
+
 func writeBlocks(w *bufio.Writer, blocks []Block) error {
 	for i, b := range blocks {
 		// Header
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+)
+
+const defaultOverlapDays = 7
+
+// fetchOptions configures the range of the quotes requested to the loaders
+type fetchOptions struct {
+	// backfill loads the full history of the securities
+	backfill bool
+	// fullRefresh loads the maximum range of the loaders, to catch older corrections
+	fullRefresh bool
+	// overlapDays are the days before the last stored quote loaded again, to catch revisions
+	overlapDays int
+}
+
+// fetchOptionsFromEnv reads the options from the env vars:
+// FETCH_OVERLAP_DAYS (default 7) and FULL_REFRESH_WEEKDAY (default "sunday"),
+// the day of the week when the full range is loaded. FULL_REFRESH=true forces it.
+func fetchOptionsFromEnv() fetchOptions {
+	opts := fetchOptions{overlapDays: defaultOverlapDays}
+
+	if overlap := os.Getenv("FETCH_OVERLAP_DAYS"); overlap != "" {
+		days, err := strconv.Atoi(overlap)
+		if err != nil || days < 0 {
+			log.Warnf("invalid FETCH_OVERLAP_DAYS '%s', using %d", overlap, defaultOverlapDays)
+		} else {
+			opts.overlapDays = days
+		}
+	}
+
+	weekday := os.Getenv("FULL_REFRESH_WEEKDAY")
+	if weekday == "" {
+		weekday = time.Sunday.String()
+	}
+	opts.fullRefresh = strings.EqualFold(weekday, time.Now().UTC().Weekday().String())
+
+	if forced, _ := strconv.ParseBool(os.Getenv("FULL_REFRESH")); forced {
+		opts.fullRefresh = true
+	}
+
+	return opts
+}
+
+// fetchQuotes loads the new quotes of the security: the full history in backfill mode,
+// the maximum range of the loader during a full refresh, otherwise only the ones after
+// the last stored quote (minus the overlap) if the loader supports it.
+func fetchQuotes(loader *security.Security, oldQuotes []security.Quote, opts fetchOptions) ([]security.Quote, error) {
+	if opts.backfill {
+		if backfiller, ok := loader.QuoteLoader.(security.Backfiller); ok {
+			log.Infof("[%s] backfilling full history", loader.ISIN())
+			return backfiller.Backfill()
+		}
+		log.Warnf("[%s] loader '%s' does not support backfill", loader.ISIN(), loader.Loader)
+		return loader.LoadQuotes()
+	}
+
+	sinceLoader, ok := loader.QuoteLoader.(security.SinceLoader)
+	if !ok || opts.fullRefresh || len(oldQuotes) == 0 {
+		return loader.LoadQuotes()
+	}
+
+	overlapDays := opts.overlapDays
+	if overlap := loader.Params["overlapDays"]; overlap != "" {
+		days, err := strconv.Atoi(overlap)
+		if err != nil || days < 0 {
+			log.Warnf("[%s] invalid overlapDays param '%s'", loader.ISIN(), overlap)
+		} else {
+			overlapDays = days
+		}
+	}
+
+	since := oldQuotes[len(oldQuotes)-1].Date.AddDate(0, 0, -overlapDays)
+	log.Debug("loading quotes incrementally", "since", since)
+
+	return sinceLoader.LoadQuotesSince(since)
+}
@@ -2,7 +2,11 @@ module github.com/enrichman/portfolio-perfomance
 
 go 1.24
 
-require github.com/charmbracelet/log v0.2.1
+require (
+	github.com/charmbracelet/log v0.2.1
+	go.etcd.io/bbolt v1.4.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/PuerkitoBio/goquery v1.5.1 // indirect
@@ -33,5 +37,5 @@ require (
 	github.com/muesli/reflow v0.3.0 // indirect
 	github.com/muesli/termenv v0.15.1 // indirect
 	github.com/rivo/uniseg v0.4.4 // indirect
-	golang.org/x/sys v0.6.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
 )
@@ -1,25 +1,49 @@
 package main
 
 import (
+	"bufio"
 	"encoding/csv"
-	"encoding/json"
-	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/enrichman/portfolio-perfomance/pkg/security"
 	"github.com/enrichman/portfolio-perfomance/pkg/security/loaders/borsaitaliana"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/loaders/csvhttp"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/loaders/execloader"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/loaders/fallback"
 	"github.com/enrichman/portfolio-perfomance/pkg/security/loaders/fondidoc"
 	"github.com/enrichman/portfolio-perfomance/pkg/security/loaders/fonte"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/loaders/htmltable"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/loaders/jsonapi"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/loaders/manual"
 	"github.com/enrichman/portfolio-perfomance/pkg/security/loaders/priamo"
 	"github.com/enrichman/portfolio-perfomance/pkg/security/loaders/raiffeisench"
 	"github.com/enrichman/portfolio-perfomance/pkg/security/loaders/secondapensione"
 	"github.com/enrichman/portfolio-perfomance/pkg/security/loaders/telemaco"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/output"
+	"github.com/enrichman/portfolio-perfomance/pkg/store"
 )
 
+const (
+	outDir         = "out"
+	defaultBaseURL = "https://enrichman.github.io/portfolio-performance"
+)
+
+// quoteStore is where the merged quotes are persisted, selected with the QUOTES_STORE env var.
+// The output files are rendered from the stored quotes.
+var quoteStore store.Store
+
+// servedBy are the sources that served the last load of the securities with a fallback
+// loader, reported in the index. The ones not loaded in the run are read from the previous index.
+var servedBy = map[string]string{}
+
+// priamoComparti are the Priamo comparti used by the registered securities.
+var priamoComparti = priamo.NewRegistry()
+
 func main() {
 	if strings.ToLower(os.Getenv("LOG_LEVEL")) == "debug" {
 		log.SetLevel(log.DebugLevel)
@@ -33,8 +57,49 @@ func main() {
 
 	log.Infof("loaded %d securities", len(security.Securities))
 
-	if len(os.Args) > 1 {
-		isin := os.Args[1]
+	if len(os.Args) > 1 && os.Args[1] == "store" {
+		if err := storeCommand(os.Args[2:]); err != nil {
+			log.Errorf("store: %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		if err := reconcileCommand(os.Args[2:]); err != nil {
+			log.Errorf("reconcile: %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	quoteStore, err = store.Open(os.Getenv("QUOTES_STORE"), outDir)
+	if err != nil {
+		log.Errorf("opening quotes store: %s", err)
+		os.Exit(1)
+	}
+	defer quoteStore.Close()
+
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		if err := serve(os.Args[2:]); err != nil {
+			log.Errorf("serving quotes: %s", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	args := os.Args[1:]
+
+	opts := fetchOptionsFromEnv()
+
+	// in backfill mode the full history of the securities is loaded
+	if len(args) > 0 && args[0] == "backfill" {
+		opts.backfill = true
+		args = args[1:]
+	}
+
+	if len(args) > 0 {
+		isin := args[0]
 
 		loader, ok := security.Securities[isin]
 		if !ok {
@@ -42,41 +107,33 @@ func main() {
 			os.Exit(1)
 		}
 
-		loadQuote(isin, loader)
+		if err := loadQuote(isin, loader, opts); err != nil {
+			log.Errorf("[%s] %s", isin, err)
+		}
 	} else {
 		for isin, loader := range security.Securities {
-			loadQuote(isin, loader)
+			if err := loadQuote(isin, loader, opts); err != nil {
+				log.Errorf("[%s] %s", isin, err)
+			}
 		}
 	}
+
+	if err := writeIndex(); err != nil {
+		log.Errorf("error writing index: %s", err)
+		os.Exit(1)
+	}
 }
 
-func loadQuote(isin string, loader security.QuoteLoader) {
+func loadQuote(isin string, loader *security.Security, opts fetchOptions) error {
 	start := time.Now().In(time.UTC)
 
 	log.Infof("[%s] loading quotes for '%s'", loader.ISIN(), loader.Name())
 
-	newQuotes, err := loader.LoadQuotes()
-	if err != nil {
-		log.Errorf("error loading quotes: %s", err)
-		return
-	}
-	if len(newQuotes) == 0 {
-		log.Warn("no quotes found")
-		return
-	}
-
-	log.Debug("new quotes loaded",
-		"from", newQuotes[0].Date,
-		"to", newQuotes[len(newQuotes)-1].Date,
-	)
+	log.Debugf("loading OLD quotes of '%s'", isin)
 
-	filename := fmt.Sprintf("out/json/%s.json", isin)
-	log.Debugf("loading OLD quotes from '%s'", filename)
-
-	oldQuotes, err := loadQuotesFromFile(filename)
+	oldQuotes, err := loadStoredQuotes(isin, loader)
 	if err != nil {
-		log.Errorf("error loading quotes: %s", err.Error())
-		return
+		return fmt.Errorf("error loading quotes: %w", err)
 	}
 
 	if len(oldQuotes) == 0 {
@@ -88,16 +145,42 @@ func loadQuote(isin string, loader security.QuoteLoader) {
 		)
 	}
 
+	newQuotes, err := fetchQuotes(loader, oldQuotes, opts)
+
+	// the series are refreshed also when the daily quotes fail or have nothing new
+	loadSeries(isin, loader)
+
+	if err != nil {
+		return fmt.Errorf("error loading quotes: %w", err)
+	}
+	if reporter, ok := loader.QuoteLoader.(security.SourceReporter); ok {
+		servedBy[isin] = strings.Join(reporter.Sources(), ",")
+		log.Infof("[%s] served by '%s'", loader.ISIN(), servedBy[isin])
+	}
+	if len(newQuotes) == 0 {
+		log.Warn("no quotes found")
+		return nil
+	}
+
+	log.Debug("new quotes loaded",
+		"from", newQuotes[0].Date,
+		"to", newQuotes[len(newQuotes)-1].Date,
+	)
+
 	mergedQuotes := security.Merge(oldQuotes, newQuotes)
 	log.Debug("merged quotes",
 		"from", mergedQuotes[0].Date,
 		"to", mergedQuotes[len(mergedQuotes)-1].Date,
 	)
 
-	err = writeQuotesToFile(filename, mergedQuotes)
+	err = quoteStore.Save(isin, mergedQuotes)
 	if err != nil {
-		log.Errorf("error writing quotes: %s", err.Error())
-		return
+		return fmt.Errorf("error saving quotes: %w", err)
+	}
+
+	err = renderOutputs(isin, loader, mergedQuotes)
+	if err != nil {
+		return err
 	}
 
 	addedQuotes := len(mergedQuotes) - len(oldQuotes)
@@ -111,46 +194,168 @@ func loadQuote(isin string, loader security.QuoteLoader) {
 	}
 
 	log.Infof("[%s] quotes loaded in %s", loader.ISIN(), time.Since(start))
+	return nil
 }
 
-func loadQuotesFromFile(filename string) ([]security.Quote, error) {
-	oldQuotesByte, err := os.ReadFile(filename)
-	if errors.Is(err, os.ErrNotExist) {
-		return nil, nil
+// loadStoredQuotes returns the stored quotes of the security. A new primary listing starts from
+// the history stored with the plain ISIN, published before the other listings were added,
+// so that its canonical file is not replaced by the quotes of the first load.
+func loadStoredQuotes(id string, s *security.Security) ([]security.Quote, error) {
+	quotes, err := quoteStore.Load(id)
+	if err != nil || len(quotes) > 0 || !s.IsPrimary() {
+		return quotes, err
 	}
+
+	quotes, err = quoteStore.Load(s.ISIN())
 	if err != nil {
-		return nil, fmt.Errorf("error reading file [%s]: %s", filename, err.Error())
+		return nil, err
 	}
+	if len(quotes) > 0 {
+		log.Infof("[%s] seeding the primary listing '%s' with %d quotes of '%s'", s.ISIN(), id, len(quotes), s.ISIN())
+	}
+	return quotes, nil
+}
 
-	var oldQuotes []security.Quote
-	err = json.Unmarshal(oldQuotesByte, &oldQuotes)
-	if err != nil {
-		return nil, fmt.Errorf("error unmarshaling file [%s]: %s", filename, err.Error())
+// loadSeries loads and writes the additional series of the security, if the loader supports them.
+// The series are published as they are loaded, without merging them with the stored history.
+// A failing series is logged, without failing the load of the security.
+func loadSeries(isin string, loader *security.Security) {
+	seriesLoader, ok := loader.QuoteLoader.(security.SeriesLoader)
+	if !ok {
+		return
 	}
 
-	return oldQuotes, nil
+	for _, series := range seriesLoader.Series() {
+		quotes, err := seriesLoader.LoadSeries(series)
+		if err != nil {
+			log.Errorf("[%s] error loading '%s' series: %s", isin, series, err)
+			continue
+		}
+
+		log.Debugf("[%s] loaded %d quotes of the '%s' series", isin, len(quotes), series)
+
+		err = output.NewSeriesWriter(series).Write(outDir, isin, quotes)
+		if err != nil {
+			log.Errorf("[%s] error writing '%s' series: %s", isin, series, err)
+		}
+	}
 }
 
-func writeQuotesToFile(filename string, quotes []security.Quote) error {
-	jsonOutput, err := json.MarshalIndent(quotes, "", "  ")
+// renderOutputs writes the output files of the security from its stored quotes.
+// The primary listing of an ISIN is also written in the canonical file of the plain ISIN.
+func renderOutputs(id string, s *security.Security, quotes []security.Quote) error {
+	writers, err := outputWriters(s.Params)
 	if err != nil {
-		return fmt.Errorf("error marshaling file [%s]: %s", filename, err.Error())
+		return fmt.Errorf("error loading output writers: %w", err)
 	}
 
-	file, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
-	if err != nil {
-		return fmt.Errorf("error opening file [%s]: %s", filename, err.Error())
+	for _, writer := range writers {
+		log.Debugf("writing '%s' output", writer.Name())
+
+		err = writer.Write(outDir, id, quotes)
+		if err != nil {
+			return fmt.Errorf("error writing quotes: %w", err)
+		}
+	}
+
+	if s.IsPrimary() {
+		log.Debugf("writing primary listing '%s'", s.ISIN())
+
+		err = (&output.JSONWriter{}).Write(outDir, s.ISIN(), quotes)
+		if err != nil {
+			return fmt.Errorf("error writing primary listing: %w", err)
+		}
 	}
-	err = file.Truncate(0)
+
+	return nil
+}
+
+// writeIndex writes the index of all the registered securities, with the
+// quotes range read from the store.
+func writeIndex() error {
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	previousEntries, err := output.ReadIndex(outDir)
 	if err != nil {
-		return fmt.Errorf("error truncating file [%s]: %s", filename, err.Error())
+		log.Warnf("error reading previous index: %s", err)
+	}
+	previousSources := map[string]string{}
+	for _, entry := range previousEntries {
+		previousSources[entry.ID()] = entry.Source
 	}
 
-	if _, err = file.Write(jsonOutput); err != nil {
-		return fmt.Errorf("error writing to file [%s]: %s", filename, err.Error())
+	entries := []output.IndexEntry{}
+	for id, s := range security.Securities {
+		quotes, err := quoteStore.Load(id)
+		if err != nil {
+			return err
+		}
+
+		source, found := servedBy[id]
+		if !found && s.Loader == "fallback" {
+			source = previousSources[id]
+		}
+
+		entry := output.IndexEntry{
+			ISIN:       s.ISIN(),
+			Listing:    s.Listing,
+			Primary:    s.IsPrimary(),
+			Name:       s.Name(),
+			Loader:     s.Loader,
+			Currency:   s.Currency(),
+			Group:      s.Group,
+			Source:     source,
+			QuoteCount: len(quotes),
+			URL:        fmt.Sprintf("%s/json/%s.json", strings.TrimSuffix(baseURL, "/"), id),
+		}
+		if bond, ok := s.Bond(); ok {
+			entry.Bond = bond
+		}
+		if len(quotes) > 0 {
+			entry.FirstDate = &quotes[0].Date
+			entry.LastDate = &quotes[len(quotes)-1].Date
+		}
+
+		entries = append(entries, entry)
 	}
 
-	return nil
+	log.Infof("writing index of %d securities", len(entries))
+	return output.WriteIndex(outDir, entries)
+}
+
+// outputWriters returns the writers for the canonical JSON format, the formats
+// selected for the run with the OUTPUT_FORMATS env var and the 'formats' of the security.
+func outputWriters(params security.Params) ([]output.Writer, error) {
+	formats := []string{}
+	// the JSON store already writes the canonical JSON file
+	if _, ok := quoteStore.(*store.JSONStore); !ok {
+		formats = append(formats, output.JSON)
+	}
+	for _, format := range strings.Split(os.Getenv("OUTPUT_FORMATS"), ",") {
+		if format = strings.TrimSpace(format); format != "" {
+			formats = append(formats, format)
+		}
+	}
+	formats = append(formats, params.List("formats")...)
+
+	writers := []output.Writer{}
+	seen := map[string]bool{}
+	for _, format := range formats {
+		if seen[format] {
+			continue
+		}
+		seen[format] = true
+
+		writer, err := output.New(format, params)
+		if err != nil {
+			return nil, err
+		}
+		writers = append(writers, writer)
+	}
+	return writers, nil
 }
 
 func loadSecuritiesFromCSV(path string) error {
@@ -160,36 +365,62 @@ func loadSecuritiesFromCSV(path string) error {
 	}
 	defer f.Close()
 
-	// read csv values using csv.Reader
-	csvReader := csv.NewReader(f)
-	csvReader.Comment = '#'
+	// the group of the securities is read from the comment headers:
+	// the "######" banners are the sections, the first comment line of a block the group
+	var section, group string
+	var inBanner, inComment bool
 
-	data, err := csvReader.ReadAll()
-	if err != nil {
-		return fmt.Errorf("reading csv: %w", err)
-	}
+	securities := []*security.Security{}
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		text := strings.TrimSpace(sc.Text())
+
+		switch {
+		case text == "":
+			inComment = false
+			continue
+
+		case strings.Trim(text, "#") == "":
+			inBanner = !inBanner
+			inComment = false
+			continue
+
+		case strings.HasPrefix(text, "#"):
+			title := strings.TrimSpace(strings.TrimLeft(text, "#"))
+			if inBanner {
+				section, group = title, ""
+			} else if !inComment {
+				group = title
+			}
+			inComment = true
+			continue
+		}
+		inComment = false
+
+		// read csv values using csv.Reader
+		line, err := csv.NewReader(strings.NewReader(text)).Read()
+		if err != nil || len(line) < 3 {
+			return fmt.Errorf("reading csv line %q: invalid line", text)
+		}
 
-	for _, line := range data {
 		isin := line[0]
 		name := line[1]
 		loader := line[2]
 
-		var quoteLoader security.QuoteLoader
-		switch loader {
-		case "borsaitaliana":
-			quoteLoader = borsaitaliana.New(name, isin)
-		case "raiffeisench":
-			quoteLoader = raiffeisench.New(name, isin)
-		case "fonte":
-			quoteLoader = fonte.New(name, isin)
-		case "priamo":
-			quoteLoader = priamo.New(name, isin)
-		case "secondapensione":
-			quoteLoader = secondapensione.New(name, isin)
-		case "telemaco":
-			quoteLoader = telemaco.New(name, isin)
-		case "fondidoc":
-			quoteLoader = fondidoc.New(name, isin)
+		params := security.Params{}
+		if len(line) > 3 {
+			params, err = security.ParseParams(line[3])
+			if err != nil {
+				log.Warnf("invalid params for ISIN %s (%s): %s", isin, name, err)
+				continue
+			}
+		}
+
+		quoteLoader, err := newQuoteLoader(loader, name, isin, params)
+		if err != nil {
+			log.Warnf("invalid quoteLoader [%s] for ISIN %s (%s): %s", loader, isin, name, err)
+			continue
 		}
 
 		if quoteLoader == nil {
@@ -197,8 +428,169 @@ func loadSecuritiesFromCSV(path string) error {
 			continue
 		}
 
-		security.Register(quoteLoader)
+		groupName := section
+		if group != "" {
+			groupName = fmt.Sprintf("%s / %s", section, group)
+		}
+
+		s := &security.Security{
+			QuoteLoader: quoteLoader,
+			Loader:      loader,
+			Group:       groupName,
+			Params:      params,
+			Key:         isin,
+		}
+
+		// the bonds must set the 'currency' param, matching the currency in their name,
+		// as checked by securities-fmt
+		if err := security.CheckBondCurrency(name, params); err != nil {
+			return fmt.Errorf("invalid currency for ISIN %s (%s): %w", isin, name, err)
+		}
+
+		securities = append(securities, s)
+	}
+
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("reading csv: %w", err)
 	}
 
+	return registerListings(securities)
+}
+
+// registerListings registers the securities. The ISINs listed on several venues are
+// registered as '<ISIN>.<VENUE>' listings, with the venue of the loader or of the 'venue' param,
+// and one of them can be the 'primary=true' listing also published with the plain ISIN.
+func registerListings(securities []*security.Security) error {
+	listings := map[string]int{}
+	for _, s := range securities {
+		listings[s.ISIN()]++
+	}
+
+	primaries := map[string]string{}
+	for _, s := range securities {
+		isin := s.ISIN()
+
+		if listings[isin] > 1 {
+			venue := s.Params["venue"]
+			if venueLoader, ok := s.QuoteLoader.(security.VenueLoader); ok && venue == "" {
+				venue = venueLoader.Venue()
+			}
+			if venue == "" {
+				return fmt.Errorf("ISIN %s is listed %d times, but the venue of '%s' is unknown: set the 'venue' param", isin, listings[isin], s.Name())
+			}
+			s.Listing = strings.ToUpper(venue)
+		}
+
+		if s.Params["primary"] == "true" {
+			if !s.IsPrimary() {
+				log.Warnf("ISIN %s has a single listing, ignoring the 'primary' param", isin)
+			} else if primary, found := primaries[isin]; found {
+				return fmt.Errorf("ISIN %s has two primary listings: %s and %s", isin, primary, s.Listing)
+			} else {
+				primaries[isin] = s.Listing
+			}
+		}
+
+		security.Register(s)
+	}
 	return nil
 }
+
+// newQuoteLoader returns the loader of the security, or nil if the loader does not exist.
+// loaderNames are the loaders of newQuoteLoader, whose '<loader>.<param>' keys are the params of a fallback source
+var loaderNames = []string{
+	"borsaitaliana", "raiffeisench", "fonte", "priamo", "secondapensione", "telemaco",
+	"fondidoc", "csvhttp", "jsonapi", "htmltable", "manual", "exec", "fallback",
+}
+
+func newQuoteLoader(loader, name, isin string, params security.Params) (security.QuoteLoader, error) {
+	switch loader {
+	case "borsaitaliana":
+		return borsaitaliana.New(name, isin, params)
+	case "raiffeisench":
+		return raiffeisench.New(name, isin, params)
+	case "fonte":
+		return fonte.New(name, isin, params)
+	case "priamo":
+		return priamo.New(name, isin, params, priamoComparti)
+	case "secondapensione":
+		return secondapensione.New(name, isin, params)
+	case "telemaco":
+		return telemaco.New(name, isin, params)
+	case "fondidoc":
+		return fondidoc.New(name, isin, params), nil
+	case "csvhttp":
+		return csvhttp.New(name, isin, params)
+	case "jsonapi":
+		return jsonapi.New(name, isin, params)
+	case "htmltable":
+		return htmltable.New(name, isin, params)
+	case "manual":
+		return manual.New(name, isin, params)
+	case "exec":
+		return execloader.New(name, isin, params)
+	case "fallback":
+		return newFallback(name, isin, params)
+	}
+
+	return nil, nil
+}
+
+// newFallback returns the loader trying the 'sources' param in order (i.e. 'sources=borsaitaliana:IT0005217770.MOT,fondidoc'),
+// or merging them with 'merge=true'. The key of a source can be replaced after the colon, and its params set
+// with the '<loader>.<param>' keys (i.e. 'fondidoc.currency=EUR'), overriding the params of the security.
+func newFallback(name, isin string, params security.Params) (security.QuoteLoader, error) {
+	sources := []fallback.Source{}
+
+	for _, spec := range params.List("sources") {
+		if loader, _, _ := strings.Cut(spec, ":"); loader == "fallback" {
+			return nil, fmt.Errorf("source '%s': fallback sources cannot be nested", spec)
+		}
+
+		source, err := newSource(spec, name, isin, params)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, source)
+	}
+
+	f, err := fallback.New(name, isin, sources, params["merge"] == "true")
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// newSource returns the source of the spec, the loader name optionally followed by the key
+// of the source (i.e. 'borsaitaliana:IT0005217770.MOT'), with its '<loader>.<param>' params.
+// The keys prefixed by the other loaders are dropped, while the other keys with a dot
+// (i.e. the 'header.<name>' of jsonapi) are passed to every source.
+func newSource(spec, name, isin string, params security.Params) (fallback.Source, error) {
+	loader, sourceISIN, found := strings.Cut(spec, ":")
+	if !found {
+		sourceISIN = isin
+	}
+
+	sourceParams := security.Params{}
+	for k, v := range params {
+		prefix, _, _ := strings.Cut(k, ".")
+		if k != "sources" && k != "merge" && (prefix == k || !slices.Contains(loaderNames, prefix)) {
+			sourceParams[k] = v
+		}
+	}
+	for k, v := range params {
+		if key, found := strings.CutPrefix(k, loader+"."); found {
+			sourceParams[key] = v
+		}
+	}
+
+	quoteLoader, err := newQuoteLoader(loader, name, sourceISIN, sourceParams)
+	if err != nil {
+		return fallback.Source{}, fmt.Errorf("source '%s': %w", spec, err)
+	}
+	if quoteLoader == nil {
+		return fallback.Source{}, fmt.Errorf("source '%s': quoteLoader [%s] not found", spec, loader)
+	}
+
+	return fallback.Source{Name: spec, QuoteLoader: quoteLoader}, nil
+}
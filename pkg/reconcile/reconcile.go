@@ -0,0 +1,270 @@
+package reconcile
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/calendar"
+)
+
+const (
+	dateFormat = "2006-01-02"
+
+	// maxListed is the number of dates and discrepancies listed in the report
+	maxListed = 10
+	// maxSpread is the maximum spread of a ratio or a difference, relative to the
+	// offset, to consider the offset systematic
+	maxSpread = 0.25
+)
+
+// Series are the quotes loaded from a source.
+type Series struct {
+	Source string
+	Quotes []security.Quote
+}
+
+// Discrepancy is a day with prices differing more than the tolerance.
+type Discrepancy struct {
+	Date    time.Time
+	Base    float32
+	Other   float32
+	DiffPct float64
+}
+
+// Report is the comparison of a series with the base one.
+type Report struct {
+	Base  Series
+	Other Series
+
+	// Common is the number of days quoted by both the sources
+	Common int
+	// MissingInBase and MissingInOther are the days quoted by only one of the sources,
+	// in the range quoted by both
+	MissingInBase  []time.Time
+	MissingInOther []time.Time
+	Discrepancies  []Discrepancy
+
+	// Ratio and Diff are the median ratio and difference of the other prices with the base ones
+	Ratio float64
+	Diff  float64
+	// Offset describes the systematic offset of the prices, if any
+	Offset string
+}
+
+// Compare aligns the series by day and compares the prices of the other series with the base
+// ones. The tolerance is the maximum difference in percent of the base price.
+func Compare(base, other Series, tolerance float64) Report {
+	// the loaders can return unsorted quotes, Merge sorts them by day
+	base.Quotes = security.Merge(nil, base.Quotes)
+	other.Quotes = security.Merge(nil, other.Quotes)
+
+	report := Report{Base: base, Other: other}
+
+	baseByDay := byDay(base.Quotes)
+	otherByDay := byDay(other.Quotes)
+
+	from, to, ok := overlap(base.Quotes, other.Quotes)
+
+	ratios, diffs := []float64{}, []float64{}
+	for _, day := range sortedDays(baseByDay, otherByDay) {
+		b, inBase := baseByDay[day]
+		o, inOther := otherByDay[day]
+
+		switch {
+		case inBase && inOther:
+			report.Common++
+			if b == 0 {
+				continue
+			}
+
+			ratios = append(ratios, float64(o)/float64(b))
+			diffs = append(diffs, float64(o)-float64(b))
+
+			diffPct := (float64(o) - float64(b)) / float64(b) * 100
+			if math.Abs(diffPct) > tolerance {
+				report.Discrepancies = append(report.Discrepancies, Discrepancy{Date: day, Base: b, Other: o, DiffPct: diffPct})
+			}
+
+		case ok && !day.Before(from) && !day.After(to) && inBase:
+			report.MissingInOther = append(report.MissingInOther, day)
+
+		case ok && !day.Before(from) && !day.After(to) && inOther:
+			report.MissingInBase = append(report.MissingInBase, day)
+		}
+	}
+
+	if len(ratios) > 0 {
+		report.Ratio = median(ratios)
+		report.Diff = median(diffs)
+		report.Offset = offset(ratios, diffs, tolerance)
+	}
+
+	return report
+}
+
+// offset describes the systematic differences of the prices. The ratio and the difference are
+// compared by their spread relative to the offset: a steadier ratio is likely a unit or currency
+// mismatch, a steadier difference the accrued interest of dirty prices. As the accrued interest
+// grows with the days, the spread of the differences is taken from their linear trend.
+func offset(ratios, diffs []float64, tolerance float64) string {
+	ratio, diff := median(ratios), median(diffs)
+
+	if math.Abs(ratio-1)*100 <= tolerance {
+		return ""
+	}
+
+	for _, unit := range []float64{100, 0.01, 1000, 0.001} {
+		if math.Abs(ratio/unit-1)*100 <= tolerance {
+			return fmt.Sprintf("constant ratio %.4f: unit mismatch (i.e. percent of par vs price)", ratio)
+		}
+	}
+
+	ratioSpread := spread(ratios, ratio) / math.Abs(ratio-1)
+	diffSpread := trendSpread(diffs) / math.Abs(diff)
+
+	switch {
+	case min(ratioSpread, diffSpread) > maxSpread:
+		return fmt.Sprintf("median ratio %.4f without a steady pattern", ratio)
+	case ratioSpread <= diffSpread:
+		return fmt.Sprintf("steady ratio %.4f: currency mismatch?", ratio)
+	default:
+		return fmt.Sprintf("steady difference %.4f: clean vs dirty prices (accrued interest)?", diff)
+	}
+}
+
+// Write writes the report in a human readable format.
+func (r Report) Write(w io.Writer) {
+	fmt.Fprintf(w, "%s vs %s\n", r.Base.Source, r.Other.Source)
+	fmt.Fprintf(w, "  quotes:         %d vs %d, %d common days\n", len(r.Base.Quotes), len(r.Other.Quotes), r.Common)
+	fmt.Fprintf(w, "  range:          %s vs %s\n", dateRange(r.Base.Quotes), dateRange(r.Other.Quotes))
+	fmt.Fprintf(w, "  missing days:   %d in %s%s\n", len(r.MissingInBase), r.Base.Source, listDates(r.MissingInBase))
+	fmt.Fprintf(w, "                  %d in %s%s\n", len(r.MissingInOther), r.Other.Source, listDates(r.MissingInOther))
+
+	if r.Common > 0 {
+		fmt.Fprintf(w, "  median ratio:   %.4f, median difference %.4f\n", r.Ratio, r.Diff)
+	}
+	if r.Offset != "" {
+		fmt.Fprintf(w, "  offset:         %s\n", r.Offset)
+	}
+
+	fmt.Fprintf(w, "  discrepancies:  %d\n", len(r.Discrepancies))
+	for i, d := range r.Discrepancies {
+		if i == maxListed {
+			fmt.Fprintf(w, "    ... %d more\n", len(r.Discrepancies)-maxListed)
+			break
+		}
+		fmt.Fprintf(w, "    %s  %v vs %v (%+.2f%%)\n", d.Date.Format(dateFormat), d.Base, d.Other, d.DiffPct)
+	}
+}
+
+func byDay(quotes []security.Quote) map[time.Time]float32 {
+	m := map[time.Time]float32{}
+	for _, q := range quotes {
+		m[calendar.UTCDay(q.Date)] = q.Close
+	}
+	return m
+}
+
+func sortedDays(maps ...map[time.Time]float32) []time.Time {
+	seen := map[time.Time]bool{}
+	days := []time.Time{}
+	for _, m := range maps {
+		for day := range m {
+			if !seen[day] {
+				seen[day] = true
+				days = append(days, day)
+			}
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+// overlap returns the range of days quoted by both the series
+func overlap(a, b []security.Quote) (time.Time, time.Time, bool) {
+	if len(a) == 0 || len(b) == 0 {
+		return time.Time{}, time.Time{}, false
+	}
+
+	from := calendar.UTCDay(a[0].Date)
+	if first := calendar.UTCDay(b[0].Date); first.After(from) {
+		from = first
+	}
+	to := calendar.UTCDay(a[len(a)-1].Date)
+	if last := calendar.UTCDay(b[len(b)-1].Date); last.Before(to) {
+		to = last
+	}
+	return from, to, !from.After(to)
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// spread returns the median absolute deviation of the values from their median
+func spread(values []float64, m float64) float64 {
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - m)
+	}
+	return median(deviations)
+}
+
+// trendSpread returns the median absolute deviation of the values from their least squares line
+func trendSpread(values []float64) float64 {
+	n := float64(len(values))
+
+	var sumX, sumY, sumXY, sumXX float64
+	for i, y := range values {
+		x := float64(i)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	slope := 0.0
+	if d := n*sumXX - sumX*sumX; d != 0 {
+		slope = (n*sumXY - sumX*sumY) / d
+	}
+	intercept := (sumY - slope*sumX) / n
+
+	residuals := make([]float64, len(values))
+	for i, y := range values {
+		residuals[i] = y - (intercept + slope*float64(i))
+	}
+	return spread(residuals, 0)
+}
+
+func dateRange(quotes []security.Quote) string {
+	if len(quotes) == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%s..%s", quotes[0].Date.Format(dateFormat), quotes[len(quotes)-1].Date.Format(dateFormat))
+}
+
+func listDates(dates []time.Time) string {
+	if len(dates) == 0 {
+		return ""
+	}
+
+	s := ":"
+	for i, d := range dates {
+		if i == maxListed {
+			return s + fmt.Sprintf(" ... %d more", len(dates)-maxListed)
+		}
+		s += " " + d.Format(dateFormat)
+	}
+	return s
+}
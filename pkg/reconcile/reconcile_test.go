@@ -0,0 +1,222 @@
+package reconcile
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+)
+
+// base are the closes of the base series, on consecutive days from 2024-01-01
+var base = []float32{100, 110, 95, 120, 90, 105, 115, 98, 102, 108}
+
+func day(i int) time.Time {
+	return time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC)
+}
+
+// series returns the series with the closes on consecutive days from 2024-01-01
+func series(source string, closes []float32) Series {
+	quotes := []security.Quote{}
+	for i, c := range closes {
+		quotes = append(quotes, security.Quote{Date: day(i), Close: c})
+	}
+	return Series{Source: source, Quotes: quotes}
+}
+
+// transform returns the closes of the base series changed by fn
+func transform(fn func(i int, c float32) float32) []float32 {
+	closes := make([]float32, len(base))
+	for i, c := range base {
+		closes[i] = fn(i, c)
+	}
+	return closes
+}
+
+func TestCompareIdentical(t *testing.T) {
+	report := Compare(series("a", base), series("b", base), 0.5)
+
+	if report.Common != len(base) {
+		t.Errorf("expected %d common days, got %d", len(base), report.Common)
+	}
+	if len(report.Discrepancies) != 0 || len(report.MissingInBase) != 0 || len(report.MissingInOther) != 0 {
+		t.Errorf("expected no differences, got %+v", report)
+	}
+	if report.Ratio != 1 || report.Diff != 0 || report.Offset != "" {
+		t.Errorf("expected no offset, got ratio %v, diff %v, offset '%s'", report.Ratio, report.Diff, report.Offset)
+	}
+}
+
+func TestCompareTolerance(t *testing.T) {
+	other := append([]float32{}, base...)
+	// +0.4% is within the tolerance, -0.6% and +1% are not
+	other[2] = 95 * 1.004
+	other[5] = 105 * 0.994
+	other[8] = 102 * 1.01
+
+	report := Compare(series("a", base), series("b", other), 0.5)
+
+	dates := []time.Time{}
+	for _, d := range report.Discrepancies {
+		dates = append(dates, d.Date)
+	}
+	if expected := []time.Time{day(5), day(8)}; !reflect.DeepEqual(dates, expected) {
+		t.Fatalf("expected discrepancies on %v, got %v", expected, dates)
+	}
+
+	d := report.Discrepancies[1]
+	if d.Base != 102 || d.Other != other[8] || d.DiffPct < 0.99 || d.DiffPct > 1.01 {
+		t.Errorf("unexpected discrepancy %+v", d)
+	}
+
+	// a wider tolerance accepts all the differences
+	if report := Compare(series("a", base), series("b", other), 1.5); len(report.Discrepancies) != 0 {
+		t.Errorf("expected no discrepancies with 1.5%% tolerance, got %d", len(report.Discrepancies))
+	}
+	// the few different days are not a systematic offset
+	if report.Offset != "" {
+		t.Errorf("expected no offset, got '%s'", report.Offset)
+	}
+}
+
+func TestCompareMissingDays(t *testing.T) {
+	a := series("a", base)
+	b := series("b", base)
+
+	// day 3 is missing in a, day 6 in b, and the days out of the common range are not missing
+	a.Quotes = append(a.Quotes[:3], a.Quotes[4:]...)
+	b.Quotes = append(b.Quotes[:6], b.Quotes[7:]...)
+	b.Quotes = append(b.Quotes, security.Quote{Date: day(20), Close: 100})
+	a.Quotes = a.Quotes[1:]
+
+	report := Compare(a, b, 0.5)
+
+	if expected := []time.Time{day(3)}; !reflect.DeepEqual(report.MissingInBase, expected) {
+		t.Errorf("expected missing in base %v, got %v", expected, report.MissingInBase)
+	}
+	if expected := []time.Time{day(6)}; !reflect.DeepEqual(report.MissingInOther, expected) {
+		t.Errorf("expected missing in other %v, got %v", expected, report.MissingInOther)
+	}
+	if report.Common != 7 {
+		t.Errorf("expected 7 common days, got %d", report.Common)
+	}
+}
+
+func TestCompareAlignsByDay(t *testing.T) {
+	a := series("a", base[:3])
+
+	// the other source has unsorted quotes, timestamped in the evening
+	b := Series{Source: "b", Quotes: []security.Quote{
+		{Date: day(2).Add(18 * time.Hour), Close: base[2]},
+		{Date: day(0).Add(18 * time.Hour), Close: base[0]},
+		{Date: day(1).Add(18 * time.Hour), Close: base[1]},
+	}}
+
+	report := Compare(a, b, 0.5)
+	if report.Common != 3 || len(report.Discrepancies) != 0 {
+		t.Errorf("expected 3 common days without discrepancies, got %d and %d", report.Common, len(report.Discrepancies))
+	}
+}
+
+func TestCompareOffset(t *testing.T) {
+	tests := []struct {
+		name   string
+		other  []float32
+		offset string
+	}{
+		{
+			name:   "percent of par vs price",
+			other:  transform(func(_ int, c float32) float32 { return c * 100 }),
+			offset: "unit mismatch",
+		},
+		{
+			name:   "price vs percent of par",
+			other:  transform(func(_ int, c float32) float32 { return c / 100 }),
+			offset: "unit mismatch",
+		},
+		{
+			name:   "currency",
+			other:  transform(func(_ int, c float32) float32 { return c * 1.08 }),
+			offset: "steady ratio 1.0800: currency mismatch?",
+		},
+		{
+			name: "accrued interest",
+			// the accrued interest of the dirty prices grows with the days
+			other:  transform(func(i int, c float32) float32 { return c + 1.5 + 0.01*float32(i) }),
+			offset: "clean vs dirty prices",
+		},
+		{
+			name:   "no pattern",
+			other:  []float32{103, 100, 105, 110, 95, 130, 100, 90, 120, 100},
+			offset: "without a steady pattern",
+		},
+		{
+			name:   "within the tolerance",
+			other:  transform(func(_ int, c float32) float32 { return c * 1.002 }),
+			offset: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Compare(series("a", base), series("b", tt.other), 0.5)
+
+			if tt.offset == "" && report.Offset != "" {
+				t.Errorf("expected no offset, got '%s'", report.Offset)
+			}
+			if !strings.Contains(report.Offset, tt.offset) {
+				t.Errorf("expected offset '%s', got '%s'", tt.offset, report.Offset)
+			}
+		})
+	}
+}
+
+func TestCompareNoCommonDays(t *testing.T) {
+	a := series("a", base[:3])
+	b := Series{Source: "b", Quotes: []security.Quote{{Date: day(10), Close: 100}}}
+
+	report := Compare(a, b, 0.5)
+	if report.Common != 0 || report.Offset != "" || len(report.MissingInBase) != 0 || len(report.MissingInOther) != 0 {
+		t.Errorf("expected an empty report, got %+v", report)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	other := transform(func(_ int, c float32) float32 { return c * 100 })
+	report := Compare(series("borsaitaliana", base), series("fondidoc:IT0005217770", other), 0.5)
+
+	var buf bytes.Buffer
+	report.Write(&buf)
+
+	for _, expected := range []string{
+		"borsaitaliana vs fondidoc:IT0005217770\n",
+		"  quotes:         10 vs 10, 10 common days\n",
+		"  range:          2024-01-01..2024-01-10 vs 2024-01-01..2024-01-10\n",
+		"  missing days:   0 in borsaitaliana\n",
+		"  median ratio:   100.0000, median difference 10246.5000\n",
+		"  offset:         constant ratio 100.0000: unit mismatch",
+		"  discrepancies:  10\n",
+		"    2024-01-01  100 vs 10000 (+9900.00%)\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("expected '%s' in the report:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestWriteListLimit(t *testing.T) {
+	closes := append(append([]float32{}, base...), base...)
+	other := make([]float32, len(closes))
+	for i, c := range closes {
+		other[i] = c * 2
+	}
+
+	var buf bytes.Buffer
+	Compare(series("a", closes), series("b", other), 0.5).Write(&buf)
+
+	if !strings.Contains(buf.String(), "  discrepancies:  20\n") || !strings.Contains(buf.String(), "    ... 10 more\n") {
+		t.Errorf("expected 10 listed discrepancies of 20:\n%s", buf.String())
+	}
+}
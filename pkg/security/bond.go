@@ -0,0 +1,180 @@
+package security
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bond is the metadata encoded in the names of the bonds of the Italian markets,
+// i.e. "Btp Tf 3,25% St46 Eur" or "Romania Fx 5.375% Mar31 Eur".
+type Bond struct {
+	Issuer string `json:"issuer"`
+	// Types are the type tokens of the name (i.e. "Tf", "Italia", "Green")
+	Types []string `json:"types,omitempty"`
+	// Coupon is the annual coupon in percent, nil if not in the name
+	Coupon *float64 `json:"coupon,omitempty"`
+	// Maturity is the maturity month ("2046-09"), or day if in the name ("2037-02-01")
+	Maturity string `json:"maturity"`
+	Currency string `json:"currency"`
+}
+
+var (
+	// bondTypes are the type tokens of the names: fixed rate (Tf, Fx), step-up (Sc),
+	// zero coupon (Zc), multi coupon (Mc) and the names of the BTP families
+	bondTypes = map[string]string{
+		"tf":     "Tf",
+		"fx":     "Fx",
+		"sc":     "Sc",
+		"zc":     "Zc",
+		"mc":     "Mc",
+		"italia": "Italia",
+		"valore": "Valore",
+		"futura": "Futura",
+		"piu'":   "Piu'",
+		"green":  "Green",
+		"call":   "Call",
+	}
+
+	// bondMonths are the Italian two letters and the English three letters abbreviations of the months
+	bondMonths = map[string]time.Month{
+		"ge": time.January, "fb": time.February, "mz": time.March, "ap": time.April,
+		"mg": time.May, "gn": time.June, "lg": time.July, "ag": time.August,
+		"st": time.September, "ot": time.October, "nv": time.November, "dc": time.December,
+
+		"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April,
+		"may": time.May, "jun": time.June, "jul": time.July, "aug": time.August,
+		"sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+	}
+
+	bondCurrencies = map[string]bool{"EUR": true, "USD": true, "CHF": true, "GBP": true}
+
+	// maturityRegexp matches the maturity tokens, with an optional day (i.e. "St46", "Oct39" or "1fb37")
+	maturityRegexp = regexp.MustCompile(`^(?i)(\d{1,2})?([a-z]{2,3})(\d{2})$`)
+	couponRegexp   = regexp.MustCompile(`^(\d+(?:[.,]\d+)?)%$`)
+)
+
+// ParseBondName parses the metadata of a bond from its name. It returns false if the name
+// does not end with the currency or has no maturity, as the names of the other securities.
+func ParseBondName(name string) (*Bond, bool) {
+	// the hyphen separates the tokens too (i.e. "Btp-1fb37 4% Eur")
+	tokens := strings.Fields(strings.ReplaceAll(name, "-", " "))
+	if len(tokens) < 3 {
+		return nil, false
+	}
+
+	bond := &Bond{Currency: strings.ToUpper(tokens[len(tokens)-1])}
+	if !bondCurrencies[bond.Currency] {
+		return nil, false
+	}
+
+	issuer := []string{}
+	inIssuer := true
+
+	for _, token := range tokens[1 : len(tokens)-1] {
+		if t, found := bondTypes[strings.ToLower(token)]; found {
+			bond.Types = append(bond.Types, t)
+			inIssuer = false
+			continue
+		}
+
+		if m := couponRegexp.FindStringSubmatch(token); m != nil {
+			coupon, err := strconv.ParseFloat(strings.ReplaceAll(m[1], ",", "."), 64)
+			if err == nil {
+				bond.Coupon = &coupon
+				inIssuer = false
+				continue
+			}
+		}
+
+		if maturity, ok := parseMaturity(token); ok && bond.Maturity == "" {
+			bond.Maturity = maturity
+			inIssuer = false
+			continue
+		}
+
+		if inIssuer {
+			issuer = append(issuer, token)
+		}
+	}
+
+	if bond.Maturity == "" {
+		return nil, false
+	}
+
+	bond.Issuer = strings.Join(append([]string{tokens[0]}, issuer...), " ")
+
+	// the zero coupon bonds have no coupon in the name
+	if bond.Coupon == nil && bond.hasType("Zc") {
+		zero := 0.0
+		bond.Coupon = &zero
+	}
+
+	return bond, true
+}
+
+// CheckBondCurrency returns an error if the name is the one of a bond and the 'currency' param
+// is missing or different from the currency in the name. The securities.csv rows of the bonds
+// state their currency explicitly, as the loaders of the markets do not report it.
+func CheckBondCurrency(name string, params Params) error {
+	bond, ok := ParseBondName(name)
+	if !ok {
+		return nil
+	}
+
+	currency := params["currency"]
+	switch {
+	case currency == "":
+		return fmt.Errorf("missing 'currency' param, expected 'currency=%s'", bond.Currency)
+	case !strings.EqualFold(currency, bond.Currency):
+		return fmt.Errorf("the bond is in %s, not %s", bond.Currency, currency)
+	}
+	return nil
+}
+
+// String returns a short description of the bond, i.e. "Tf 3.25% 2046-09".
+func (b *Bond) String() string {
+	parts := append([]string{}, b.Types...)
+	if b.Coupon != nil {
+		parts = append(parts, strconv.FormatFloat(*b.Coupon, 'f', -1, 64)+"%")
+	}
+	parts = append(parts, b.Maturity)
+	return strings.Join(parts, " ")
+}
+
+func (b *Bond) hasType(t string) bool {
+	for _, bt := range b.Types {
+		if bt == t {
+			return true
+		}
+	}
+	return false
+}
+
+// parseMaturity returns the maturity of the token, with the day if present
+func parseMaturity(token string) (string, bool) {
+	m := maturityRegexp.FindStringSubmatch(token)
+	if m == nil {
+		return "", false
+	}
+
+	month, found := bondMonths[strings.ToLower(m[2])]
+	if !found {
+		return "", false
+	}
+	year, _ := strconv.Atoi(m[3])
+	year += 2000
+
+	if m[1] == "" {
+		return fmt.Sprintf("%d-%02d", year, month), true
+	}
+
+	day, _ := strconv.Atoi(m[1])
+	t := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	if t.Month() != month {
+		return "", false
+	}
+	return t.Format("2006-01-02"), true
+}
@@ -0,0 +1,164 @@
+package security
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func coupon(c float64) *float64 { return &c }
+
+func TestParseBondName(t *testing.T) {
+	tests := []struct {
+		name string
+		bond *Bond
+	}{
+		{
+			name: "Btp Tf 3,25% St46 Eur",
+			bond: &Bond{Issuer: "Btp", Types: []string{"Tf"}, Coupon: coupon(3.25), Maturity: "2046-09", Currency: "EUR"},
+		},
+		{
+			name: "Btp Italia Mz28 Eur",
+			bond: &Bond{Issuer: "Btp", Types: []string{"Italia"}, Maturity: "2028-03", Currency: "EUR"},
+		},
+		{
+			name: "Btp Valore Sc Ot32 Eur",
+			bond: &Bond{Issuer: "Btp", Types: []string{"Valore", "Sc"}, Maturity: "2032-10", Currency: "EUR"},
+		},
+		{
+			name: "Btpi Tf 1,30% Mg28 Eur",
+			bond: &Bond{Issuer: "Btpi", Types: []string{"Tf"}, Coupon: coupon(1.3), Maturity: "2028-05", Currency: "EUR"},
+		},
+		{
+			name: "Btp Tf 1,75% Lg24 Eur",
+			bond: &Bond{Issuer: "Btp", Types: []string{"Tf"}, Coupon: coupon(1.75), Maturity: "2024-07", Currency: "EUR"},
+		},
+		{
+			name: "Btp Tf 0,95% Dc31 Eur",
+			bond: &Bond{Issuer: "Btp", Types: []string{"Tf"}, Coupon: coupon(0.95), Maturity: "2031-12", Currency: "EUR"},
+		},
+		{
+			name: "Btp Tf 2% Ge25 Eur",
+			bond: &Bond{Issuer: "Btp", Types: []string{"Tf"}, Coupon: coupon(2), Maturity: "2025-01", Currency: "EUR"},
+		},
+		{
+			name: "Btp Fx 4.15% Oct39 Eur",
+			bond: &Bond{Issuer: "Btp", Types: []string{"Fx"}, Coupon: coupon(4.15), Maturity: "2039-10", Currency: "EUR"},
+		},
+		{
+			name: "Romania Fx 5.375% Mar31 Eur",
+			bond: &Bond{Issuer: "Romania", Types: []string{"Fx"}, Coupon: coupon(5.375), Maturity: "2031-03", Currency: "EUR"},
+		},
+		{
+			name: "Btp-1fb37 4% Eur",
+			bond: &Bond{Issuer: "Btp", Coupon: coupon(4), Maturity: "2037-02-01", Currency: "EUR"},
+		},
+		{
+			name: "Btp-01St40 5% Eur",
+			bond: &Bond{Issuer: "Btp", Coupon: coupon(5), Maturity: "2040-09-01", Currency: "EUR"},
+		},
+		{
+			name: "Bot Zc Ag24 A Eur",
+			bond: &Bond{Issuer: "Bot", Types: []string{"Zc"}, Coupon: coupon(0), Maturity: "2024-08", Currency: "EUR"},
+		},
+		{
+			name: "Ctz Zc Gn26 Eur",
+			bond: &Bond{Issuer: "Ctz", Types: []string{"Zc"}, Coupon: coupon(0), Maturity: "2026-06", Currency: "EUR"},
+		},
+		{
+			name: "Gs Fin Corp Mc Sep35 Call Usd",
+			bond: &Bond{Issuer: "Gs Fin Corp", Types: []string{"Mc", "Call"}, Maturity: "2035-09", Currency: "USD"},
+		},
+		{
+			name: "Usa Tf 0,875% Nv30 Usd",
+			bond: &Bond{Issuer: "Usa", Types: []string{"Tf"}, Coupon: coupon(0.875), Maturity: "2030-11", Currency: "USD"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bond, ok := ParseBondName(tt.name)
+			if !ok {
+				t.Fatalf("expected a bond")
+			}
+			if !reflect.DeepEqual(bond, tt.bond) {
+				t.Errorf("unexpected bond:\nexpected %+v\ngot      %+v", *tt.bond, *bond)
+			}
+		})
+	}
+}
+
+func TestParseBondNameNotBond(t *testing.T) {
+	for _, name := range []string{
+		"",
+		"Eur",
+		"Btp Eur",
+		// the names of the funds and the pension funds
+		"Swisscanto (CH) Index Equity Fund Switzerland Total (II) FA CHF",
+		"Fondo Pensione Priamo - Comparto Bilanciato Sviluppo",
+		"iShares Core MSCI World UCITS ETF USD (Acc)",
+		// no maturity
+		"Btp Tf 3,25% Eur",
+		// invalid months and days
+		"Btp Tf 3,25% Xy46 Eur",
+		"Btp-31fb37 4% Eur",
+		// unknown currency
+		"Btp Tf 3,25% St46 Jpy",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if bond, ok := ParseBondName(name); ok {
+				t.Errorf("expected no bond, got %+v", *bond)
+			}
+		})
+	}
+}
+
+func TestBondString(t *testing.T) {
+	tests := map[string]string{
+		"Btp Tf 3,25% St46 Eur": "Tf 3.25% 2046-09",
+		"Btp-1fb37 4% Eur":      "4% 2037-02-01",
+		"Bot Zc Ag24 A Eur":     "Zc 0% 2024-08",
+		"Btp Italia Mz28 Eur":   "Italia 2028-03",
+	}
+
+	for name, expected := range tests {
+		bond, ok := ParseBondName(name)
+		if !ok {
+			t.Fatalf("[%s] expected a bond", name)
+		}
+		if s := bond.String(); s != expected {
+			t.Errorf("[%s] expected '%s', got '%s'", name, expected, s)
+		}
+	}
+}
+
+func TestCheckBondCurrency(t *testing.T) {
+	tests := []struct {
+		name   string
+		params Params
+		err    string
+	}{
+		{name: "Btp Tf 3,25% St46 Eur", params: Params{"currency": "EUR"}},
+		{name: "Btp Tf 3,25% St46 Eur", params: Params{"currency": "eur"}},
+		{name: "Btp Tf 3,25% St46 Eur", params: Params{}, err: "missing 'currency' param, expected 'currency=EUR'"},
+		{name: "Isp Sc Jun36 Usd", params: Params{"currency": "EUR"}, err: "the bond is in USD, not EUR"},
+		// the other securities do not need the param
+		{name: "Fondo Pensione Priamo - Comparto Bilanciato Sviluppo", params: Params{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckBondCurrency(tt.name, tt.params)
+
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error '%s', got %v", tt.err, err)
+			}
+		})
+	}
+}
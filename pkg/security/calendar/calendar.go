@@ -0,0 +1,161 @@
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+// Calendar is an exchange or settlement calendar, with its timezone and holidays.
+type Calendar struct {
+	Name     string
+	Location *time.Location
+
+	// holidays returns the closing days of the year, weekends excluded
+	holidays func(year int) []time.Time
+}
+
+var (
+	// BorsaItaliana is the calendar of the Borsa Italiana markets.
+	BorsaItaliana = &Calendar{
+		Name:     "borsaitaliana",
+		Location: mustLoadLocation("Europe/Rome"),
+		holidays: func(year int) []time.Time {
+			easter := easterSunday(year)
+			return []time.Time{
+				date(year, time.January, 1),
+				easter.AddDate(0, 0, -2), // Good Friday
+				easter.AddDate(0, 0, 1),  // Easter Monday
+				date(year, time.May, 1),
+				date(year, time.August, 15),
+				date(year, time.December, 24),
+				date(year, time.December, 25),
+				date(year, time.December, 26),
+				date(year, time.December, 31),
+			}
+		},
+	}
+
+	// SIX is the calendar of the SIX Swiss Exchange.
+	SIX = &Calendar{
+		Name:     "six",
+		Location: mustLoadLocation("Europe/Zurich"),
+		holidays: func(year int) []time.Time {
+			easter := easterSunday(year)
+			return []time.Time{
+				date(year, time.January, 1),
+				date(year, time.January, 2),
+				easter.AddDate(0, 0, -2), // Good Friday
+				easter.AddDate(0, 0, 1),  // Easter Monday
+				easter.AddDate(0, 0, 39), // Ascension Day
+				easter.AddDate(0, 0, 50), // Whit Monday
+				date(year, time.May, 1),
+				date(year, time.August, 1),
+				date(year, time.December, 24),
+				date(year, time.December, 25),
+				date(year, time.December, 26),
+				date(year, time.December, 31),
+			}
+		},
+	}
+
+	// TARGET2 is the calendar of the TARGET2 settlement system, used for the
+	// valuation of the funds.
+	TARGET2 = &Calendar{
+		Name:     "target2",
+		Location: mustLoadLocation("Europe/Berlin"),
+		holidays: func(year int) []time.Time {
+			easter := easterSunday(year)
+			return []time.Time{
+				date(year, time.January, 1),
+				easter.AddDate(0, 0, -2), // Good Friday
+				easter.AddDate(0, 0, 1),  // Easter Monday
+				date(year, time.May, 1),
+				date(year, time.December, 25),
+				date(year, time.December, 26),
+			}
+		},
+	}
+)
+
+// Get returns the calendar with the name.
+func Get(name string) (*Calendar, error) {
+	for _, c := range []*Calendar{BorsaItaliana, SIX, TARGET2} {
+		if strings.EqualFold(c.Name, name) {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown calendar '%s'", name)
+}
+
+// Day returns the calendar day of t in the timezone of the calendar, at midnight UTC.
+// This is the date used for all the quotes.
+func (c *Calendar) Day(t time.Time) time.Time {
+	year, month, day := t.In(c.Location).Date()
+	return date(year, month, day)
+}
+
+// IsBusinessDay returns true if the calendar day of t is not a weekend day or a holiday.
+func (c *Calendar) IsBusinessDay(t time.Time) bool {
+	day := c.Day(t)
+
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return false
+	}
+
+	for _, holiday := range c.holidays(day.Year()) {
+		if holiday.Equal(day) {
+			return false
+		}
+	}
+	return true
+}
+
+// LastBusinessDay returns the last business day of the month, used as the date of the monthly NAVs.
+func (c *Calendar) LastBusinessDay(year int, month time.Month) time.Time {
+	// the day 0 of the next month is the last day of the month
+	day := date(year, month+1, 0)
+	for !c.IsBusinessDay(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// UTCDay returns the calendar day of t in UTC, at midnight.
+func UTCDay(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return date(year, month, day)
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// easterSunday returns the Easter Sunday of the year (Anonymous Gregorian algorithm)
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+
+	return date(year, time.Month(month), day)
+}
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
@@ -0,0 +1,155 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEasterSunday(t *testing.T) {
+	tests := map[int]time.Time{
+		1818: date(1818, time.March, 22),
+		2000: date(2000, time.April, 23),
+		2008: date(2008, time.March, 23),
+		2011: date(2011, time.April, 24),
+		2019: date(2019, time.April, 21),
+		2020: date(2020, time.April, 12),
+		2021: date(2021, time.April, 4),
+		2024: date(2024, time.March, 31),
+		2025: date(2025, time.April, 20),
+		2038: date(2038, time.April, 25),
+	}
+
+	for year, expected := range tests {
+		if easter := easterSunday(year); !easter.Equal(expected) {
+			t.Errorf("[%d] expected %s, got %s", year, expected.Format(time.DateOnly), easter.Format(time.DateOnly))
+		}
+	}
+}
+
+func TestIsBusinessDay(t *testing.T) {
+	tests := []struct {
+		name     string
+		calendar *Calendar
+		day      time.Time
+		business bool
+	}{
+		{name: "Good Friday 2024", calendar: BorsaItaliana, day: date(2024, time.March, 29)},
+		{name: "Easter Monday 2024", calendar: BorsaItaliana, day: date(2024, time.April, 1)},
+		{name: "Good Friday 2025", calendar: TARGET2, day: date(2025, time.April, 18)},
+		{name: "Easter Monday 2025", calendar: TARGET2, day: date(2025, time.April, 21)},
+		{name: "Easter Monday 2019", calendar: SIX, day: date(2019, time.April, 22)},
+		{name: "Thursday before Easter", calendar: BorsaItaliana, day: date(2024, time.March, 28), business: true},
+		{name: "Tuesday after Easter", calendar: TARGET2, day: date(2025, time.April, 22), business: true},
+
+		{name: "Ascension Day", calendar: SIX, day: date(2024, time.May, 9)},
+		{name: "Whit Monday", calendar: SIX, day: date(2024, time.May, 20)},
+		{name: "Whit Monday on Borsa Italiana", calendar: BorsaItaliana, day: date(2024, time.May, 20), business: true},
+		{name: "Swiss National Day", calendar: SIX, day: date(2024, time.August, 1)},
+		{name: "Berchtoldstag", calendar: SIX, day: date(2024, time.January, 2)},
+		{name: "Berchtoldstag on Borsa Italiana", calendar: BorsaItaliana, day: date(2024, time.January, 2), business: true},
+		{name: "Ferragosto", calendar: BorsaItaliana, day: date(2024, time.August, 15)},
+		{name: "Ferragosto on TARGET2", calendar: TARGET2, day: date(2024, time.August, 15), business: true},
+		{name: "Labour Day", calendar: TARGET2, day: date(2024, time.May, 1)},
+
+		{name: "Christmas Eve", calendar: BorsaItaliana, day: date(2024, time.December, 24)},
+		{name: "Christmas Eve on TARGET2", calendar: TARGET2, day: date(2024, time.December, 24), business: true},
+		{name: "Christmas", calendar: TARGET2, day: date(2024, time.December, 25)},
+		{name: "Boxing Day", calendar: SIX, day: date(2024, time.December, 26)},
+		{name: "New Year's Eve", calendar: BorsaItaliana, day: date(2024, time.December, 31)},
+		{name: "New Year's Eve on TARGET2", calendar: TARGET2, day: date(2024, time.December, 31), business: true},
+		{name: "New Year's Day", calendar: SIX, day: date(2025, time.January, 1)},
+
+		{name: "Saturday", calendar: TARGET2, day: date(2024, time.June, 29)},
+		{name: "Sunday", calendar: BorsaItaliana, day: date(2024, time.June, 30)},
+		{name: "Monday", calendar: SIX, day: date(2024, time.July, 1), business: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if business := tt.calendar.IsBusinessDay(tt.day); business != tt.business {
+				t.Errorf("%s on %s: expected business day %v, got %v", tt.day.Format(time.DateOnly), tt.calendar.Name, tt.business, business)
+			}
+		})
+	}
+}
+
+func TestLastBusinessDay(t *testing.T) {
+	tests := []struct {
+		name     string
+		calendar *Calendar
+		year     int
+		month    time.Month
+		day      time.Time
+	}{
+		{name: "last day of the month", calendar: TARGET2, year: 2024, month: time.January, day: date(2024, time.January, 31)},
+		{name: "leap year", calendar: TARGET2, year: 2024, month: time.February, day: date(2024, time.February, 29)},
+		{name: "Saturday", calendar: TARGET2, year: 2024, month: time.August, day: date(2024, time.August, 30)},
+		{name: "Sunday", calendar: TARGET2, year: 2024, month: time.June, day: date(2024, time.June, 28)},
+		{name: "Easter weekend", calendar: TARGET2, year: 2024, month: time.March, day: date(2024, time.March, 28)},
+		{name: "Good Friday and Saturday", calendar: BorsaItaliana, year: 2018, month: time.March, day: date(2018, time.March, 29)},
+		{name: "New Year's Eve", calendar: BorsaItaliana, year: 2024, month: time.December, day: date(2024, time.December, 30)},
+		{name: "New Year's Eve on TARGET2", calendar: TARGET2, year: 2024, month: time.December, day: date(2024, time.December, 31)},
+		{name: "New Year's Eve on a Sunday", calendar: BorsaItaliana, year: 2023, month: time.December, day: date(2023, time.December, 29)},
+		{name: "New Year's Eve on SIX", calendar: SIX, year: 2025, month: time.December, day: date(2025, time.December, 30)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if day := tt.calendar.LastBusinessDay(tt.year, tt.month); !day.Equal(tt.day) {
+				t.Errorf("expected %s, got %s", tt.day.Format(time.DateOnly), day.Format(time.DateOnly))
+			}
+		})
+	}
+}
+
+func TestDay(t *testing.T) {
+	tests := []struct {
+		name     string
+		calendar *Calendar
+		t        time.Time
+		day      time.Time
+	}{
+		{name: "late evening UTC in winter", calendar: BorsaItaliana, t: time.Date(2024, time.January, 2, 23, 30, 0, 0, time.UTC), day: date(2024, time.January, 3)},
+		{name: "late evening UTC in summer", calendar: SIX, t: time.Date(2024, time.July, 1, 22, 30, 0, 0, time.UTC), day: date(2024, time.July, 2)},
+		{name: "before midnight in summer", calendar: TARGET2, t: time.Date(2024, time.July, 1, 21, 59, 0, 0, time.UTC), day: date(2024, time.July, 1)},
+		{name: "other timezone", calendar: BorsaItaliana, t: time.Date(2024, time.January, 2, 20, 0, 0, 0, time.FixedZone("EST", -5*3600)), day: date(2024, time.January, 3)},
+		{name: "midnight", calendar: TARGET2, t: date(2024, time.January, 2), day: date(2024, time.January, 2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			day := tt.calendar.Day(tt.t)
+			if !day.Equal(tt.day) || day.Location() != time.UTC {
+				t.Errorf("expected %v, got %v", tt.day, day)
+			}
+		})
+	}
+}
+
+func TestUTCDay(t *testing.T) {
+	rome := time.FixedZone("CET", 3600)
+
+	if day := UTCDay(time.Date(2024, time.January, 3, 0, 30, 0, 0, rome)); !day.Equal(date(2024, time.January, 2)) {
+		t.Errorf("expected 2024-01-02, got %v", day)
+	}
+	if day := UTCDay(time.Date(2024, time.January, 2, 15, 0, 0, 0, time.UTC)); !day.Equal(date(2024, time.January, 2)) {
+		t.Errorf("expected 2024-01-02, got %v", day)
+	}
+}
+
+func TestGet(t *testing.T) {
+	for name, expected := range map[string]*Calendar{
+		"borsaitaliana": BorsaItaliana,
+		"SIX":           SIX,
+		"Target2":       TARGET2,
+	} {
+		c, err := Get(name)
+		if err != nil || c != expected {
+			t.Errorf("[%s] expected the %s calendar, got %v (%v)", name, expected.Name, c, err)
+		}
+	}
+
+	if _, err := Get("nyse"); err == nil {
+		t.Errorf("expected an unknown calendar error")
+	}
+}
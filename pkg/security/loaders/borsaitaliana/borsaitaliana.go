@@ -6,36 +6,87 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 	"time"
 
+	"github.com/charmbracelet/log"
 	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/calendar"
 )
 
+const (
+	// backfillWindow is the range of every request done to backfill the history
+	backfillWindow = 5
+	// backfillLimit is the oldest date requested during a backfill
+	backfillLimit = 1990
+
+	dateFormat = "2006-01-02T15:04:05"
+
+	// the chart price types: the market price, or the NAV of the ETFs
+	defaultPriceType = "price"
+	navPriceType     = "nav"
+)
+
+// chartsURL is the endpoint of the charts service, replaced in the tests
+var chartsURL = "https://charts.borsaitaliana.it/charts/services/ChartWService.asmx/GetPricesWithVolume"
+
+// seriesRequests are the additional series that can be enabled with the 'series' param
+var seriesRequests = map[string]struct {
+	sampleTime string
+	timeFrame  string
+	intraday   bool
+}{
+	"weekly":   {sampleTime: "1w", timeFrame: "5y"},
+	"monthly":  {sampleTime: "1M", timeFrame: "10y"},
+	"intraday": {sampleTime: "5m", timeFrame: "1d", intraday: true},
+}
+
 type BorsaItalianaQuoteLoader struct {
 	name             string
 	isin             string
 	market           string
 	alphanumericCode string
+	priceType        string
+	series           []string
 }
 
-func New(name, isin string) *BorsaItalianaQuoteLoader {
-	isinMarketCode := strings.Split(isin, ".")
+// New returns the loader of the security. The ISIN has the market segment and the
+// alphanumeric code suffixes (i.e. "IT0005532723.MOT" or "DE000VD5HH87.MCW.F47661"), see segments.
+// The params are:
+//   - series: the additional series published with the daily quotes (weekly, monthly, intraday)
+//   - priceType: 'nav' to load the NAV instead of the market price, only for the ETFplus segment
+func New(name, isin string, params security.Params) (*BorsaItalianaQuoteLoader, error) {
+	isin, market, code, err := ParseKey(isin)
+	if err != nil {
+		return nil, err
+	}
 
 	loader := &BorsaItalianaQuoteLoader{
-		name: name,
-		isin: isinMarketCode[0],
+		name:             name,
+		isin:             isin,
+		market:           market,
+		alphanumericCode: code,
+		priceType:        defaultPriceType,
 	}
 
-	if len(isinMarketCode) > 1 {
-		loader.market = isinMarketCode[1]
+	for _, series := range params.List("series") {
+		if _, found := seriesRequests[series]; !found {
+			return nil, fmt.Errorf("unknown series '%s'", series)
+		}
+		loader.series = append(loader.series, series)
 	}
 
-	if len(isinMarketCode) > 2 {
-		loader.alphanumericCode = isinMarketCode[2]
+	switch priceType := params["priceType"]; priceType {
+	case "", defaultPriceType:
+	case navPriceType:
+		if !segments[market].nav {
+			return nil, fmt.Errorf("the NAV is not available for the %s segment", segments[market].name)
+		}
+		loader.priceType = navPriceType
+	default:
+		return nil, fmt.Errorf("invalid priceType '%s': expected '%s' or '%s'", priceType, defaultPriceType, navPriceType)
 	}
 
-	return loader
+	return loader, nil
 }
 
 func (b *BorsaItalianaQuoteLoader) Name() string {
@@ -47,12 +98,12 @@ func (b *BorsaItalianaQuoteLoader) ISIN() string {
 }
 
 type Data struct {
-	Data [][5]float32 `json:"d"`
+	Data [][5]float64 `json:"d"`
 }
 
 type RequestPayload struct {
 	SampleTime           string
-	TimeFrame            string
+	TimeFrame            string `json:",omitempty"`
 	RequestedDataSetType string
 	ChartPriceType       string
 	Key                  string
@@ -66,22 +117,135 @@ type RequestPayload struct {
 }
 
 func (b *BorsaItalianaQuoteLoader) LoadQuotes() ([]security.Quote, error) {
+	payload := b.newPayload()
+	payload.TimeFrame = "5y"
+
+	return b.loadQuotes(payload)
+}
+
+// LoadQuotesSince loads the quotes from the since date to today.
+func (b *BorsaItalianaQuoteLoader) LoadQuotesSince(since time.Time) ([]security.Quote, error) {
+	return b.loadRange(since, time.Now().UTC())
+}
+
+// Backfill loads the full history of the security, paging through windows of
+// 'backfillWindow' years back to the listing date, when no more quotes are returned.
+func (b *BorsaItalianaQuoteLoader) Backfill() ([]security.Quote, error) {
+	quotes := []security.Quote{}
+
+	to := time.Now().UTC()
+	for to.Year() >= backfillLimit {
+		from := to.AddDate(-backfillWindow, 0, 0)
+
+		windowQuotes, err := b.loadRange(from, to)
+		if err != nil {
+			return nil, err
+		}
+		if len(windowQuotes) == 0 {
+			break
+		}
+
+		log.Debug("backfill window loaded",
+			"from", from,
+			"to", to,
+			"quotes", len(windowQuotes),
+		)
+
+		quotes = security.Merge(quotes, windowQuotes)
+		to = from
+	}
+
+	return quotes, nil
+}
+
+// Venue returns the market of the listing (i.e. "MOT" or "TLX").
+func (b *BorsaItalianaQuoteLoader) Venue() string {
+	return b.market
+}
+
+// Series returns the additional series enabled for the security.
+func (b *BorsaItalianaQuoteLoader) Series() []string {
+	return b.series
+}
+
+// LoadSeries loads the bars of the series. The intraday quotes keep their time,
+// the other ones are dated on their calendar day.
+func (b *BorsaItalianaQuoteLoader) LoadSeries(series string) ([]security.Quote, error) {
+	request, found := seriesRequests[series]
+	if !found {
+		return nil, fmt.Errorf("unknown series '%s'", series)
+	}
+
+	payload := b.newPayload()
+	payload.SampleTime = request.sampleTime
+	payload.TimeFrame = request.timeFrame
+
+	if !request.intraday {
+		return b.loadQuotes(payload)
+	}
+
+	bars, err := b.loadBars(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes := []security.Quote{}
+	for _, bar := range bars {
+		quotes = append(quotes, security.Quote{
+			Date:  time.UnixMilli(int64(bar[0])).UTC(),
+			Close: float32(bar[1]),
+		})
+	}
+
+	return quotes, nil
+}
+
+func (b *BorsaItalianaQuoteLoader) loadRange(from, to time.Time) ([]security.Quote, error) {
+	payload := b.newPayload()
+	payload.FromDate = from.UTC().Format(dateFormat)
+	payload.ToDate = to.UTC().Format(dateFormat)
+
+	return b.loadQuotes(payload)
+}
+
+func (b *BorsaItalianaQuoteLoader) newPayload() RequestPayload {
 	payload := RequestPayload{
 		SampleTime:           "1d",
-		TimeFrame:            "5y",
 		RequestedDataSetType: "ohlc",
-		ChartPriceType:       "price",
+		ChartPriceType:       b.priceType,
 		Key:                  fmt.Sprintf("%s.%s", b.isin, b.market),
 		KeyType:              "Topic",
 		KeyType2:             "Topic",
 		Language:             "en-US",
 	}
 
-	// if the alphanumeric code is not empty, use it as the key (CW and Certificates)
-	if b.alphanumericCode != "" && b.market == "MCW" {
+	// the segments loaded by code use it as the key (i.e. shares, CW and certificates)
+	if b.alphanumericCode != "" && segments[b.market].byCode {
 		payload.Key = fmt.Sprintf("%s.%s", b.alphanumericCode, b.market)
 	}
 
+	return payload
+}
+
+func (b *BorsaItalianaQuoteLoader) loadQuotes(payload RequestPayload) ([]security.Quote, error) {
+	bars, err := b.loadBars(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes := []security.Quote{}
+	for _, quote := range bars {
+		quotes = append(quotes, security.Quote{
+			Date:  calendar.BorsaItaliana.Day(time.UnixMilli(int64(quote[0]))),
+			Close: float32(quote[1]),
+		})
+	}
+
+	return quotes, nil
+}
+
+// loadBars returns the bars of the request, with the time in milliseconds and the price as the first values
+func (b *BorsaItalianaQuoteLoader) loadBars(payload RequestPayload) ([][5]float64, error) {
 	payloadBytes, err := json.Marshal(struct {
 		Request RequestPayload `json:"request"`
 	}{Request: payload})
@@ -89,14 +253,16 @@ func (b *BorsaItalianaQuoteLoader) LoadQuotes() ([]security.Quote, error) {
 		return nil, fmt.Errorf("error marshaling request body")
 	}
 
-	res, err := http.Post(
-		"https://charts.borsaitaliana.it/charts/services/ChartWService.asmx/GetPricesWithVolume",
-		"application/json",
-		bytes.NewBuffer(payloadBytes),
-	)
+	res, err := http.Post(chartsURL, "application/json", bytes.NewBuffer(payloadBytes))
 	if err != nil {
 		return nil, fmt.Errorf("error during post request: %w", err)
 	}
+	defer res.Body.Close()
+
+	// the errors are HTML pages, not to be decoded
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("error from request: status_code %d", res.StatusCode)
+	}
 
 	bodyBytes, err := io.ReadAll(res.Body)
 	if err != nil {
@@ -109,13 +275,5 @@ func (b *BorsaItalianaQuoteLoader) LoadQuotes() ([]security.Quote, error) {
 		return nil, fmt.Errorf("error unmarshaling body: %w", err)
 	}
 
-	quotes := []security.Quote{}
-	for _, quote := range result.Data {
-		quotes = append(quotes, security.Quote{
-			Date:  time.Unix(int64(quote[0]/1000), 0).In(time.UTC),
-			Close: quote[1],
-		})
-	}
-
-	return quotes, nil
+	return result.Data, nil
 }
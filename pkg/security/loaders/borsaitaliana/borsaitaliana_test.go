@@ -0,0 +1,166 @@
+package borsaitaliana
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+)
+
+// newTestServer serves the fixture of the charts service, and records the request payloads
+func newTestServer(t *testing.T, fixture string, status int) *[]RequestPayload {
+	t.Helper()
+
+	body, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payloads := []RequestPayload{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Request RequestPayload `json:"request"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		payloads = append(payloads, request.Request)
+
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(ts.Close)
+
+	previousURL := chartsURL
+	chartsURL = ts.URL
+	t.Cleanup(func() { chartsURL = previousURL })
+
+	return &payloads
+}
+
+func TestLoadQuotesPayload(t *testing.T) {
+	tests := []struct {
+		key       string
+		params    security.Params
+		isin      string
+		venue     string
+		payload   string
+		priceType string
+	}{
+		{key: "IT0005532723.MOT", isin: "IT0005532723", venue: "MOT", payload: "IT0005532723.MOT", priceType: "price"},
+		{key: "XS2533094400.TLX", isin: "XS2533094400", venue: "TLX", payload: "XS2533094400.TLX", priceType: "price"},
+		{key: "IE00B4L5Y983.ETF", isin: "IE00B4L5Y983", venue: "ETF", payload: "IE00B4L5Y983.ETF", priceType: "price"},
+		{key: "IE00B4L5Y983.ETF", params: security.Params{"priceType": "nav"}, isin: "IE00B4L5Y983", venue: "ETF", payload: "IE00B4L5Y983.ETF", priceType: "nav"},
+		{key: "IT0003132476.MTA.ENI", isin: "IT0003132476", venue: "MTA", payload: "ENI.MTA", priceType: "price"},
+		{key: "DE000VD5HH87.MCW.F47661", isin: "DE000VD5HH87", venue: "MCW", payload: "F47661.MCW", priceType: "price"},
+		{key: "DE000VD5HH87.MCW", isin: "DE000VD5HH87", venue: "MCW", payload: "DE000VD5HH87.MCW", priceType: "price"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+" "+tt.priceType, func(t *testing.T) {
+			payloads := newTestServer(t, "testdata/prices.json", http.StatusOK)
+
+			loader, err := New("Test", tt.key, tt.params)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if loader.ISIN() != tt.isin || loader.Venue() != tt.venue {
+				t.Errorf("expected ISIN %s on %s, got %s on %s", tt.isin, tt.venue, loader.ISIN(), loader.Venue())
+			}
+
+			quotes, err := loader.LoadQuotes()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			expected := []security.Quote{
+				{Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Close: 101.2},
+				{Date: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), Close: 101.4},
+				{Date: time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC), Close: 101.9},
+			}
+			if !reflect.DeepEqual(quotes, expected) {
+				t.Errorf("unexpected quotes:\nexpected %v\ngot      %v", expected, quotes)
+			}
+
+			if len(*payloads) != 1 {
+				t.Fatalf("expected 1 request, got %d", len(*payloads))
+			}
+			payload := (*payloads)[0]
+
+			if payload.Key != tt.payload {
+				t.Errorf("expected Key '%s', got '%s'", tt.payload, payload.Key)
+			}
+			if payload.ChartPriceType != tt.priceType {
+				t.Errorf("expected ChartPriceType '%s', got '%s'", tt.priceType, payload.ChartPriceType)
+			}
+			if payload.SampleTime != "1d" || payload.TimeFrame != "5y" {
+				t.Errorf("expected the daily bars of 5 years, got '%s' of '%s'", payload.SampleTime, payload.TimeFrame)
+			}
+		})
+	}
+}
+
+func TestLoadQuotesSincePayload(t *testing.T) {
+	payloads := newTestServer(t, "testdata/prices.json", http.StatusOK)
+
+	loader, err := New("Test", "IT0005532723.MOT", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loader.LoadQuotesSince(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	payload := (*payloads)[0]
+	if payload.FromDate != "2024-01-02T00:00:00" || payload.ToDate == "" || payload.TimeFrame != "" {
+		t.Errorf("expected the range from 2024-01-02, got '%s' to '%s' (time frame '%s')", payload.FromDate, payload.ToDate, payload.TimeFrame)
+	}
+}
+
+func TestLoadQuotesErrorStatus(t *testing.T) {
+	newTestServer(t, "testdata/error.html", http.StatusInternalServerError)
+
+	loader, err := New("Test", "IT0005532723.MOT", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = loader.LoadQuotes()
+	if err == nil || !strings.Contains(err.Error(), "status_code 500") {
+		t.Errorf("expected a status error, got %v", err)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		key    string
+		params security.Params
+		err    string
+	}{
+		{key: "IT0005532723", err: "invalid key"},
+		{key: "IT0005532723.MOT", params: security.Params{"priceType": "nav"}, err: "the NAV is not available for the MOT segment"},
+		{key: "XS2533094400.TLX", params: security.Params{"priceType": "nav"}, err: "the NAV is not available for the EuroTLX segment"},
+		{key: "IT0003132476.MTA.ENI", params: security.Params{"priceType": "nav"}, err: "the NAV is not available for the Euronext Milan segment"},
+		{key: "DE000VD5HH87.MCW.F47661", params: security.Params{"priceType": "nav"}, err: "the NAV is not available for the SeDeX segment"},
+		{key: "IE00B4L5Y983.ETF", params: security.Params{"priceType": "bid"}, err: "invalid priceType 'bid'"},
+		{key: "IT0005532723.MOT", params: security.Params{"series": "daily"}, err: "unknown series 'daily'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			_, err := New("Test", tt.key, tt.params)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error '%s', got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package borsaitaliana
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// segment is a market segment of Borsa Italiana, with the format of its chart keys
+type segment struct {
+	name string
+	// byCode is true if the chart key is the alphanumeric code of the instrument instead of its ISIN
+	byCode bool
+	// codeRequired is true if the segment cannot be loaded by ISIN
+	codeRequired bool
+	// nav is true if the segment publishes the NAV of the instruments
+	nav bool
+}
+
+// segments are the supported market segments, by the suffix of the ISIN in securities.csv:
+//
+//	IT0005532723.MOT          MOT, bonds by ISIN
+//	XS2533094400.TLX          EuroTLX, bonds by ISIN
+//	IE00B4L5Y983.ETF          ETFplus, ETFs and ETCs by ISIN
+//	IT0003132476.MTA.ENI      Euronext Milan, shares by ticker
+//	DE000VD5HH87.MCW.F47661   SeDeX, certificates and covered warrants by code
+var segments = map[string]segment{
+	"MOT": {name: "MOT"},
+	"TLX": {name: "EuroTLX"},
+	"ETF": {name: "ETFplus", nav: true},
+	"MTA": {name: "Euronext Milan", byCode: true, codeRequired: true},
+	"MCW": {name: "SeDeX", byCode: true},
+}
+
+var (
+	isinRegexp = regexp.MustCompile(`^[A-Z]{2}[A-Z0-9]{9}[0-9]$`)
+	codeRegexp = regexp.MustCompile(`^[A-Z0-9]+$`)
+)
+
+// ParseKey splits the identifier of a security in securities.csv in the ISIN, the market segment
+// and the alphanumeric code (i.e. "DE000VD5HH87.MCW.F47661"), validating them.
+func ParseKey(id string) (isin, market, code string, err error) {
+	parts := strings.Split(id, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return "", "", "", fmt.Errorf("invalid key '%s': expected '<ISIN>.<MARKET>' or '<ISIN>.<MARKET>.<CODE>'", id)
+	}
+
+	isin, market = parts[0], parts[1]
+	if len(parts) == 3 {
+		code = parts[2]
+	}
+
+	if !isinRegexp.MatchString(isin) {
+		return "", "", "", fmt.Errorf("invalid ISIN '%s' in key '%s'", isin, id)
+	}
+
+	seg, found := segments[market]
+	if !found {
+		return "", "", "", fmt.Errorf("unknown market '%s' in key '%s': expected one of %s", market, id, strings.Join(markets(), ", "))
+	}
+
+	switch {
+	case code == "" && seg.codeRequired:
+		return "", "", "", fmt.Errorf("missing code in key '%s': the %s instruments are loaded by code, i.e. '%s.%s.<CODE>'", id, seg.name, isin, market)
+	case code != "" && !seg.byCode:
+		return "", "", "", fmt.Errorf("unexpected code in key '%s': the %s instruments are loaded by ISIN", id, seg.name)
+	case code != "" && !codeRegexp.MatchString(code):
+		return "", "", "", fmt.Errorf("invalid code '%s' in key '%s'", code, id)
+	}
+
+	return isin, market, code, nil
+}
+
+func markets() []string {
+	names := []string{}
+	for market := range segments {
+		names = append(names, market)
+	}
+	sort.Strings(names)
+	return names
+}
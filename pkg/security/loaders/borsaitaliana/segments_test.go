@@ -0,0 +1,54 @@
+package borsaitaliana
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		key    string
+		isin   string
+		market string
+		code   string
+		err    string
+	}{
+		{key: "IT0005532723.MOT", isin: "IT0005532723", market: "MOT"},
+		{key: "XS2533094400.TLX", isin: "XS2533094400", market: "TLX"},
+		{key: "IE00B4L5Y983.ETF", isin: "IE00B4L5Y983", market: "ETF"},
+		{key: "IT0003132476.MTA.ENI", isin: "IT0003132476", market: "MTA", code: "ENI"},
+		{key: "DE000VD5HH87.MCW.F47661", isin: "DE000VD5HH87", market: "MCW", code: "F47661"},
+		{key: "DE000VD5HH87.MCW", isin: "DE000VD5HH87", market: "MCW"},
+
+		{key: "IT0005532723", err: "invalid key 'IT0005532723'"},
+		{key: "IT0005532723.MOT.A.B", err: "invalid key"},
+		{key: "IT000553272.MOT", err: "invalid ISIN 'IT000553272'"},
+		{key: "it0005532723.MOT", err: "invalid ISIN 'it0005532723'"},
+		{key: "IT0005532723.XETRA", err: "unknown market 'XETRA' in key 'IT0005532723.XETRA': expected one of ETF, MCW, MOT, MTA, TLX"},
+		{key: "IT0005532723.mot", err: "unknown market 'mot'"},
+		{key: "IT0003132476.MTA", err: "missing code in key 'IT0003132476.MTA'"},
+		{key: "IT0005532723.MOT.X1", err: "unexpected code in key 'IT0005532723.MOT.X1': the MOT instruments are loaded by ISIN"},
+		{key: "IE00B4L5Y983.ETF.SWDA", err: "unexpected code"},
+		{key: "IT0003132476.MTA.eni", err: "invalid code 'eni'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			isin, market, code, err := ParseKey(tt.key)
+
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("expected error '%s', got %v", tt.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if isin != tt.isin || market != tt.market || code != tt.code {
+				t.Errorf("expected (%s, %s, %s), got (%s, %s, %s)", tt.isin, tt.market, tt.code, isin, market, code)
+			}
+		})
+	}
+}
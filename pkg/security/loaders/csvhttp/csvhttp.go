@@ -0,0 +1,261 @@
+package csvhttp
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/log"
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/locale"
+)
+
+const (
+	defaultDateLayout = "2006-01-02"
+	defaultLocale     = "en"
+)
+
+// client can also read the local 'file://' URLs, i.e. to try a configuration against a downloaded file.
+// Their paths are rooted at the working directory, and cannot read the other files of the host.
+var client = &http.Client{Transport: fileTransport()}
+
+// CSVHTTP loads the quotes from a CSV file published at an URL, configured with the params of the security.
+type CSVHTTP struct {
+	name string
+	isin string
+
+	url         string
+	delimiter   rune
+	header      bool
+	dateColumn  string
+	valueColumn string
+	dateLayout  string
+	locale      *locale.Locale
+	filters     []filter
+}
+
+// filter keeps only the rows with the value in the column
+type filter struct {
+	column string
+	value  string
+}
+
+// New returns the loader of the security. The params are:
+//   - url: the URL of the CSV file, where '{isin}' and '{code}' are replaced by the ISIN and the 'code' param
+//   - delimiter: the field delimiter (default ',', 'semicolon' and 'tab' for ';' and tabs)
+//   - header: 'false' if the file has no header row (default 'true')
+//   - dateColumn, valueColumn: the headers of the date and value columns, or their 1-based numbers
+//   - dateLayout: the Go layout of the dates (default '2006-01-02')
+//   - locale: the locale of the month names and numbers (en, it, de or fr, default 'en')
+//   - filter: the 'column:value' rows to keep, comma separated
+func New(name, isin string, params security.Params) (*CSVHTTP, error) {
+	c := &CSVHTTP{
+		name:        name,
+		isin:        isin,
+		delimiter:   ',',
+		header:      params["header"] != "false",
+		dateColumn:  params["dateColumn"],
+		valueColumn: params["valueColumn"],
+		dateLayout:  params["dateLayout"],
+	}
+
+	if params["url"] == "" {
+		return nil, fmt.Errorf("missing 'url' param")
+	}
+	c.url = strings.NewReplacer(
+		"{isin}", url.PathEscape(isin),
+		"{code}", url.PathEscape(params["code"]),
+	).Replace(params["url"])
+
+	switch delimiter := params["delimiter"]; {
+	case delimiter == "":
+	case delimiter == "semicolon":
+		c.delimiter = ';'
+	case delimiter == "tab":
+		c.delimiter = '\t'
+	case utf8.RuneCountInString(delimiter) == 1:
+		c.delimiter, _ = utf8.DecodeRuneInString(delimiter)
+	default:
+		return nil, fmt.Errorf("invalid delimiter '%s'", delimiter)
+	}
+
+	if c.dateColumn == "" || c.valueColumn == "" {
+		return nil, fmt.Errorf("missing 'dateColumn' or 'valueColumn' param")
+	}
+
+	if c.dateLayout == "" {
+		c.dateLayout = defaultDateLayout
+	}
+
+	localeName := params["locale"]
+	if localeName == "" {
+		localeName = defaultLocale
+	}
+	l, err := locale.Get(localeName)
+	if err != nil {
+		return nil, err
+	}
+	c.locale = l
+
+	for _, f := range params.List("filter") {
+		column, value, found := strings.Cut(f, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid filter '%s': expected 'column:value'", f)
+		}
+		c.filters = append(c.filters, filter{column: column, value: value})
+	}
+
+	if !c.header {
+		for _, column := range append([]string{c.dateColumn, c.valueColumn}, c.filterColumns()...) {
+			if _, err := strconv.Atoi(column); err != nil {
+				return nil, fmt.Errorf("column '%s' must be a number in a file without header", column)
+			}
+		}
+	}
+
+	return c, nil
+}
+
+func (c *CSVHTTP) Name() string { return c.name }
+
+func (c *CSVHTTP) ISIN() string { return c.isin }
+
+func (c *CSVHTTP) LoadQuotes() ([]security.Quote, error) {
+	resp, err := client.Get(c.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("error from request: status_code %d", resp.StatusCode)
+	}
+
+	return c.parse(resp.Body)
+}
+
+func (c *CSVHTTP) parse(r io.Reader) ([]security.Quote, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = c.delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV '%s': %w", c.url, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("empty CSV file '%s'", c.url)
+	}
+
+	var header []string
+	firstRow := 1
+	if c.header {
+		header = records[0]
+		records = records[1:]
+		firstRow = 2
+	}
+
+	dateIndex, err := columnIndex(header, c.dateColumn)
+	if err != nil {
+		return nil, err
+	}
+	valueIndex, err := columnIndex(header, c.valueColumn)
+	if err != nil {
+		return nil, err
+	}
+
+	filterIndexes := make([]int, len(c.filters))
+	for i, f := range c.filters {
+		if filterIndexes[i], err = columnIndex(header, f.column); err != nil {
+			return nil, err
+		}
+	}
+
+	quotes := []security.Quote{}
+	for i, record := range records {
+		row := i + firstRow
+
+		if !c.matches(record, filterIndexes) {
+			continue
+		}
+
+		if len(record) <= max(dateIndex, valueIndex) {
+			log.Warnf("[%s] skipping row %d: expected at least %d columns, got %d", c.isin, row, max(dateIndex, valueIndex)+1, len(record))
+			continue
+		}
+
+		date, err := c.locale.ParseDate(c.dateLayout, record[dateIndex])
+		if err != nil {
+			log.Warnf("[%s] skipping row %d: invalid date '%s'", c.isin, row, record[dateIndex])
+			continue
+		}
+
+		closeQuote, err := c.locale.ParseFloat(record[valueIndex])
+		if err != nil {
+			log.Warnf("[%s] skipping row %d: %s", c.isin, row, err)
+			continue
+		}
+
+		quotes = append(quotes, security.Quote{
+			Date:  date,
+			Close: float32(closeQuote),
+		})
+	}
+
+	sort.Slice(quotes, func(i, j int) bool {
+		return quotes[i].Date.Before(quotes[j].Date)
+	})
+
+	return quotes, nil
+}
+
+// matches returns true if the record has the values of all the filters
+func (c *CSVHTTP) matches(record []string, filterIndexes []int) bool {
+	for i, f := range c.filters {
+		index := filterIndexes[i]
+		if index >= len(record) || !strings.EqualFold(strings.TrimSpace(record[index]), f.value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *CSVHTTP) filterColumns() []string {
+	columns := []string{}
+	for _, f := range c.filters {
+		columns = append(columns, f.column)
+	}
+	return columns
+}
+
+// columnIndex returns the index of the column, from its 1-based number or from its
+// header, matched case insensitively
+func columnIndex(header []string, column string) (int, error) {
+	if n, err := strconv.Atoi(column); err == nil {
+		if n < 1 {
+			return 0, fmt.Errorf("invalid column number %d", n)
+		}
+		return n - 1, nil
+	}
+
+	for i, h := range header {
+		h = strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+		if strings.EqualFold(h, column) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("column '%s' not found in header %q", column, header)
+}
+
+func fileTransport() http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.RegisterProtocol("file", http.NewFileTransport(http.Dir(".")))
+	return t
+}
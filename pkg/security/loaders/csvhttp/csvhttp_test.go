@@ -0,0 +1,272 @@
+package csvhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+)
+
+const testISIN = "IT0000000001"
+
+// newTestServer serves the files of the testdata directory, and records the requested paths
+func newTestServer(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+
+	requested := []string{}
+	files := http.FileServer(http.Dir("testdata"))
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.RequestURI())
+		files.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts, &requested
+}
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestLoadQuotes(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		params string
+		quotes []security.Quote
+	}{
+		{
+			name:   "comma delimited with header",
+			params: "url=" + ts.URL + "/comma.csv&dateColumn=Date&valueColumn=Close",
+			quotes: []security.Quote{
+				{Date: day(2024, 1, 2), Close: 100.25},
+				{Date: day(2024, 1, 3), Close: 101.5},
+				{Date: day(2024, 1, 4), Close: 102.75},
+			},
+		},
+		{
+			name:   "columns by number",
+			params: "url=" + ts.URL + "/comma.csv&dateColumn=1&valueColumn=2",
+			quotes: []security.Quote{
+				{Date: day(2024, 1, 2), Close: 100.1},
+				{Date: day(2024, 1, 3), Close: 101.2},
+				{Date: day(2024, 1, 4), Close: 102},
+			},
+		},
+		{
+			name:   "headers matched case insensitively",
+			params: "url=" + ts.URL + "/comma.csv&dateColumn=date&valueColumn=CLOSE",
+			quotes: []security.Quote{
+				{Date: day(2024, 1, 2), Close: 100.25},
+				{Date: day(2024, 1, 3), Close: 101.5},
+				{Date: day(2024, 1, 4), Close: 102.75},
+			},
+		},
+		{
+			name:   "semicolon delimited with Italian decimals and filter",
+			params: "url=" + ts.URL + "/semicolon.csv&delimiter=semicolon&dateColumn=Data&valueColumn=Valore quota&dateLayout=02/01/2006&locale=it&filter=Classe:a",
+			quotes: []security.Quote{
+				{Date: day(2024, 1, 2), Close: 1234.56},
+				{Date: day(2024, 1, 3), Close: 1240.01},
+			},
+		},
+		{
+			name:   "filter by column number",
+			params: "url=" + ts.URL + "/semicolon.csv&delimiter=%3B&dateColumn=1&valueColumn=3&dateLayout=02/01/2006&locale=it&filter=2:B",
+			quotes: []security.Quote{
+				{Date: day(2024, 1, 2), Close: 1100.10},
+				{Date: day(2024, 1, 3), Close: 1105.20},
+			},
+		},
+		{
+			name:   "no header with Italian month names",
+			params: "url=" + ts.URL + "/noheader.csv&delimiter=semicolon&header=false&dateColumn=1&valueColumn=2&dateLayout=02-Jan-2006&locale=it",
+			quotes: []security.Quote{
+				{Date: day(2024, 1, 2), Close: 10.512},
+				{Date: day(2024, 5, 31), Close: 10.874},
+				{Date: day(2024, 12, 2), Close: 11.03},
+			},
+		},
+		{
+			name:   "invalid rows are skipped",
+			params: "url=" + ts.URL + "/invalid.csv&dateColumn=date&valueColumn=close",
+			quotes: []security.Quote{
+				{Date: day(2024, 1, 2), Close: 100},
+				{Date: day(2024, 1, 5), Close: 103.5},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := security.ParseParams(tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			loader, err := New("Test", testISIN, params)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			quotes, err := loader.LoadQuotes()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(quotes, tt.quotes) {
+				t.Errorf("unexpected quotes:\nexpected %v\ngot      %v", tt.quotes, quotes)
+			}
+		})
+	}
+}
+
+func TestLoadQuotesURLPlaceholders(t *testing.T) {
+	ts, requested := newTestServer(t)
+
+	params := security.Params{
+		"url":         ts.URL + "/comma.csv?isin={isin}&code={code}",
+		"code":        "A 1",
+		"dateColumn":  "Date",
+		"valueColumn": "Close",
+	}
+
+	loader, err := New("Test", testISIN, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loader.LoadQuotes(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"/comma.csv?isin=" + testISIN + "&code=A%201"}
+	if !reflect.DeepEqual(*requested, expected) {
+		t.Errorf("expected requests %v, got %v", expected, *requested)
+	}
+}
+
+func TestLoadQuotesErrors(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		params string
+		err    string
+	}{
+		{
+			name:   "missing file",
+			params: "url=" + ts.URL + "/missing.csv&dateColumn=Date&valueColumn=Close",
+			err:    "status_code 404",
+		},
+		{
+			name:   "unknown column",
+			params: "url=" + ts.URL + "/comma.csv&dateColumn=Date&valueColumn=Price",
+			err:    "column 'Price' not found",
+		},
+		{
+			name:   "unknown filter column",
+			params: "url=" + ts.URL + "/comma.csv&dateColumn=Date&valueColumn=Close&filter=Class:A",
+			err:    "column 'Class' not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := security.ParseParams(tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			loader, err := New("Test", testISIN, params)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			_, err = loader.LoadQuotes()
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error '%s', got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// the file URLs are rooted at the working directory
+func TestLoadQuotesFileURL(t *testing.T) {
+	loader, err := New("Test", testISIN, security.Params{"url": "file:///testdata/comma.csv", "dateColumn": "Date", "valueColumn": "Close"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quotes, err := loader.LoadQuotes()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(quotes) != 3 {
+		t.Errorf("expected 3 quotes, got %v", quotes)
+	}
+
+	for _, path := range []string{"/etc/hostname", "/../../../../go.mod", "/../csvhttp/testdata/comma.csv"} {
+		loader, err := New("Test", testISIN, security.Params{"url": "file://" + path, "dateColumn": "Date", "valueColumn": "Close"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = loader.LoadQuotes()
+		if err == nil || !strings.Contains(err.Error(), "status_code 404") {
+			t.Errorf("[%s] expected a not found error, got %v", path, err)
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		params security.Params
+		err    string
+	}{
+		{
+			name:   "missing url",
+			params: security.Params{"dateColumn": "Date", "valueColumn": "Close"},
+			err:    "missing 'url' param",
+		},
+		{
+			name:   "missing columns",
+			params: security.Params{"url": "http://localhost/file.csv", "dateColumn": "Date"},
+			err:    "missing 'dateColumn' or 'valueColumn' param",
+		},
+		{
+			name:   "invalid delimiter",
+			params: security.Params{"url": "http://localhost/file.csv", "dateColumn": "Date", "valueColumn": "Close", "delimiter": "||"},
+			err:    "invalid delimiter '||'",
+		},
+		{
+			name:   "unknown locale",
+			params: security.Params{"url": "http://localhost/file.csv", "dateColumn": "Date", "valueColumn": "Close", "locale": "es"},
+			err:    "unknown locale 'es'",
+		},
+		{
+			name:   "invalid filter",
+			params: security.Params{"url": "http://localhost/file.csv", "dateColumn": "Date", "valueColumn": "Close", "filter": "Class"},
+			err:    "invalid filter 'Class'",
+		},
+		{
+			name:   "column name without header",
+			params: security.Params{"url": "http://localhost/file.csv", "dateColumn": "Date", "valueColumn": "2", "header": "false"},
+			err:    "column 'Date' must be a number",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New("Test", testISIN, tt.params)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error '%s', got %v", tt.err, err)
+			}
+		})
+	}
+}
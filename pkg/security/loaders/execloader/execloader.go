@@ -0,0 +1,231 @@
+package execloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/calendar"
+)
+
+const (
+	defaultTimeout = time.Minute
+	dateFormat     = "2006-01-02"
+
+	// maxStderr is the size of the end of the stderr output kept in the errors
+	maxStderr = 1024
+)
+
+// Exec runs an external command to load the quotes, for the loaders written in other languages.
+//
+// The command receives a JSON request on stdin:
+//
+//	{"isin": "IT0000000000", "name": "Fund", "params": {"code": "123"}, "since": "2024-01-01"}
+//
+// where 'since' is set only for the incremental loads, and must write on stdout the quotes:
+//
+//	[{"date": "2024-01-02", "close": 10.52}]
+//
+// or an error:
+//
+//	{"error": "fund not found"}
+//
+// The stderr output is logged, and a non zero exit code fails the load.
+type Exec struct {
+	name    string
+	isin    string
+	command []string
+	timeout time.Duration
+	params  security.Params
+}
+
+// Request is the JSON written on the stdin of the command.
+type Request struct {
+	ISIN   string          `json:"isin"`
+	Name   string          `json:"name"`
+	Params security.Params `json:"params"`
+	Since  string          `json:"since,omitempty"`
+}
+
+// Quote is a quote written on the stdout of the command.
+type Quote struct {
+	Date  *string  `json:"date"`
+	Close *float64 `json:"close"`
+}
+
+// ErrorResponse is the error written on the stdout of the command.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// New returns the loader of the security. The 'command' param is the command to run, with its
+// arguments separated by spaces (i.e. 'python3 loaders/myfund.py'), and 'timeout' its
+// maximum duration (default '1m'). All the params are sent to the command.
+func New(name, isin string, params security.Params) (*Exec, error) {
+	e := &Exec{
+		name:    name,
+		isin:    isin,
+		command: strings.Fields(params["command"]),
+		timeout: defaultTimeout,
+		params:  params,
+	}
+
+	if len(e.command) == 0 {
+		return nil, fmt.Errorf("missing 'command' param")
+	}
+
+	if timeout := params["timeout"]; timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid timeout '%s'", timeout)
+		}
+		e.timeout = d
+	}
+
+	return e, nil
+}
+
+func (e *Exec) Name() string { return e.name }
+
+func (e *Exec) ISIN() string { return e.isin }
+
+func (e *Exec) LoadQuotes() ([]security.Quote, error) {
+	return e.run(Request{ISIN: e.isin, Name: e.name, Params: e.params})
+}
+
+// LoadQuotesSince sends the 'since' date to the command, that can ignore it and return all the quotes.
+func (e *Exec) LoadQuotesSince(since time.Time) ([]security.Quote, error) {
+	return e.run(Request{ISIN: e.isin, Name: e.name, Params: e.params, Since: since.UTC().Format(dateFormat)})
+}
+
+func (e *Exec) run(request Request) ([]security.Quote, error) {
+	stdin, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, e.command[0], e.command[1:]...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// the children of the command can keep the pipes open after it is killed
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+
+	for _, line := range strings.Split(strings.TrimSpace(stderr.String()), "\n") {
+		if line != "" {
+			log.Debugf("[%s] %s: %s", e.isin, e.command[0], line)
+		}
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("command '%s' timed out after %s%s", e.command[0], e.timeout, stderrTail(stderr.Bytes()))
+	}
+	if err != nil {
+		// the command can exit with an error response
+		if errResp, ok := parseError(stdout.Bytes()); ok {
+			return nil, fmt.Errorf("command '%s' failed: %s", e.command[0], errResp)
+		}
+		return nil, fmt.Errorf("command '%s' failed: %w%s", e.command[0], err, stderrTail(stderr.Bytes()))
+	}
+
+	return parseResponse(stdout.Bytes())
+}
+
+// parseResponse validates the quotes written by the command, or returns its error
+func parseResponse(stdout []byte) ([]security.Quote, error) {
+	stdout = bytes.TrimSpace(stdout)
+
+	if errResp, ok := parseError(stdout); ok {
+		return nil, fmt.Errorf("command error: %s", errResp)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(stdout))
+	decoder.DisallowUnknownFields()
+
+	var response []Quote
+	if err := decoder.Decode(&response); err != nil {
+		return nil, fmt.Errorf("invalid response: expected a quotes array or an error object: %w", err)
+	}
+
+	var errs error
+	quotes := []security.Quote{}
+	for i, q := range response {
+		if q.Date == nil || q.Close == nil {
+			errs = errors.Join(errs, fmt.Errorf("quote %d: missing 'date' or 'close'", i))
+			continue
+		}
+
+		date, err := parseDate(*q.Date)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("quote %d: invalid date '%s'", i, *q.Date))
+			continue
+		}
+		if *q.Close <= 0 {
+			errs = errors.Join(errs, fmt.Errorf("quote %d: invalid close %v", i, *q.Close))
+			continue
+		}
+
+		quotes = append(quotes, security.Quote{
+			Date:  date,
+			Close: float32(*q.Close),
+		})
+	}
+	if errs != nil {
+		return nil, fmt.Errorf("invalid response: %w", errs)
+	}
+
+	sort.Slice(quotes, func(i, j int) bool {
+		return quotes[i].Date.Before(quotes[j].Date)
+	})
+
+	return quotes, nil
+}
+
+// parseError returns the message of the error object, if the output is an error
+func parseError(stdout []byte) (string, bool) {
+	var errResp ErrorResponse
+	if !bytes.HasPrefix(bytes.TrimSpace(stdout), []byte("{")) || json.Unmarshal(stdout, &errResp) != nil || errResp.Error == "" {
+		return "", false
+	}
+	return errResp.Error, true
+}
+
+// parseDate parses the 'YYYY-MM-DD' dates, or the RFC 3339 timestamps of the JSON output files
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(dateFormat, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return calendar.UTCDay(t), nil
+}
+
+// stderrTail returns the end of the stderr output, to be appended to the errors
+func stderrTail(stderr []byte) string {
+	stderr = bytes.TrimSpace(stderr)
+	if len(stderr) == 0 {
+		return ""
+	}
+	if len(stderr) > maxStderr {
+		stderr = append([]byte("..."), stderr[len(stderr)-maxStderr:]...)
+	}
+	return fmt.Sprintf(": stderr: %s", stderr)
+}
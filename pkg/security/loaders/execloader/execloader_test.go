@@ -0,0 +1,281 @@
+package execloader
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+)
+
+// newShell returns the loader running the script with 'sh -c'
+func newShell(script string, timeout time.Duration) *Exec {
+	return &Exec{
+		name:    "Test",
+		isin:    "IT0000000001",
+		command: []string{"sh", "-c", script},
+		timeout: timeout,
+		params:  security.Params{"code": "123"},
+	}
+}
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestLoadQuotes(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		quotes []security.Quote
+	}{
+		{
+			name:   "quotes sorted by date",
+			script: `echo '[{"date": "2024-01-03", "close": 10.61}, {"date": "2024-01-02", "close": 10.52}]'`,
+			quotes: []security.Quote{
+				{Date: day(2024, 1, 2), Close: 10.52},
+				{Date: day(2024, 1, 3), Close: 10.61},
+			},
+		},
+		{
+			name:   "timestamps of the JSON output files",
+			script: `echo '[{"date": "2024-01-02T23:00:00+01:00", "close": 10.52}]'`,
+			quotes: []security.Quote{
+				{Date: day(2024, 1, 2), Close: 10.52},
+			},
+		},
+		{
+			name:   "no quotes",
+			script: `echo '[]'`,
+			quotes: []security.Quote{},
+		},
+		{
+			name:   "stderr output is only logged",
+			script: `echo 'loading the fund' >&2; echo '[{"date": "2024-01-02", "close": 1}]'`,
+			quotes: []security.Quote{
+				{Date: day(2024, 1, 2), Close: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quotes, err := newShell(tt.script, time.Minute).LoadQuotes()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(quotes, tt.quotes) {
+				t.Errorf("unexpected quotes:\nexpected %v\ngot      %v", tt.quotes, quotes)
+			}
+		})
+	}
+}
+
+func TestLoadQuotesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		timeout time.Duration
+		err     string
+	}{
+		{
+			name:   "error object with exit code 0",
+			script: `echo '{"error": "fund not found"}'`,
+			err:    "command error: fund not found",
+		},
+		{
+			name:   "error object with a non zero exit code",
+			script: `echo '{"error": "fund not found"}'; echo 'traceback' >&2; exit 3`,
+			err:    "command 'sh' failed: fund not found",
+		},
+		{
+			name:   "non zero exit code",
+			script: `echo 'connection refused' >&2; exit 2`,
+			err:    "command 'sh' failed: exit status 2: stderr: connection refused",
+		},
+		{
+			name:   "non zero exit code with quotes",
+			script: `echo '[{"date": "2024-01-02", "close": 1}]'; exit 1`,
+			err:    "command 'sh' failed: exit status 1",
+		},
+		{
+			name:    "timeout",
+			script:  `echo 'waiting' >&2; sleep 5`,
+			timeout: 100 * time.Millisecond,
+			err:     "command 'sh' timed out after 100ms: stderr: waiting",
+		},
+		{
+			name:   "unknown fields",
+			script: `echo '[{"date": "2024-01-02", "close": 1, "open": 2}]'`,
+			err:    `json: unknown field "open"`,
+		},
+		{
+			name:   "missing date",
+			script: `echo '[{"close": 1}]'`,
+			err:    "quote 0: missing 'date' or 'close'",
+		},
+		{
+			name:   "missing close",
+			script: `echo '[{"date": "2024-01-02"}, {"date": "2024-01-03", "close": 1}, {"date": "2024-01-04"}]'`,
+			err:    "quote 0: missing 'date' or 'close'\nquote 2: missing 'date' or 'close'",
+		},
+		{
+			name:   "invalid date and close",
+			script: `echo '[{"date": "02/01/2024", "close": 1}, {"date": "2024-01-03", "close": 0}]'`,
+			err:    "quote 0: invalid date '02/01/2024'\nquote 1: invalid close 0",
+		},
+		{
+			name:   "not JSON",
+			script: `echo 'date,close'`,
+			err:    "invalid response: expected a quotes array or an error object",
+		},
+		{
+			name:   "empty output",
+			script: `true`,
+			err:    "invalid response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeout := tt.timeout
+			if timeout == 0 {
+				timeout = time.Minute
+			}
+
+			start := time.Now()
+			_, err := newShell(tt.script, timeout).LoadQuotes()
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error '%s', got %v", tt.err, err)
+			}
+			if tt.timeout > 0 && time.Since(start) > 3*time.Second {
+				t.Errorf("expected the command to be killed after %s, took %s", tt.timeout, time.Since(start))
+			}
+		})
+	}
+}
+
+// the request is written on the stdin of the command
+func TestRequest(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "request.json")
+	loader := newShell(`cat > "$0"; echo '[]'`, time.Minute)
+	loader.command = append(loader.command, file)
+
+	tests := []struct {
+		name    string
+		load    func() ([]security.Quote, error)
+		request Request
+	}{
+		{
+			name:    "load",
+			load:    loader.LoadQuotes,
+			request: Request{ISIN: "IT0000000001", Name: "Test", Params: security.Params{"code": "123"}},
+		},
+		{
+			name: "load since",
+			load: func() ([]security.Quote, error) {
+				return loader.LoadQuotesSince(time.Date(2024, 1, 2, 23, 0, 0, 0, time.FixedZone("CET", 3600)))
+			},
+			request: Request{ISIN: "IT0000000001", Name: "Test", Params: security.Params{"code": "123"}, Since: "2024-01-02"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.load(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var request Request
+			if err := json.Unmarshal(data, &request); err != nil {
+				t.Fatalf("invalid request %s: %s", data, err)
+			}
+			if !reflect.DeepEqual(request, tt.request) {
+				t.Errorf("expected the request %+v, got %+v", tt.request, request)
+			}
+			// the since date is omitted from the full loads
+			if tt.request.Since == "" && strings.Contains(string(data), "since") {
+				t.Errorf("unexpected since in %s", data)
+			}
+		})
+	}
+}
+
+func TestStderrTail(t *testing.T) {
+	if tail := stderrTail([]byte(" \n")); tail != "" {
+		t.Errorf("expected no tail for an empty stderr, got '%s'", tail)
+	}
+
+	if tail := stderrTail([]byte("connection refused\n")); tail != ": stderr: connection refused" {
+		t.Errorf("unexpected tail '%s'", tail)
+	}
+
+	long := strings.Repeat("x", 2*maxStderr) + "END"
+	tail := stderrTail([]byte(long))
+	if !strings.HasPrefix(tail, ": stderr: ...") || !strings.HasSuffix(tail, "END") {
+		t.Errorf("expected the end of the stderr, got '%s'", tail)
+	}
+	if n := len(strings.TrimPrefix(tail, ": stderr: ...")); n != maxStderr {
+		t.Errorf("expected %d bytes of stderr, got %d", maxStderr, n)
+	}
+}
+
+// the long stderr of a failing command is truncated in the error
+func TestLoadQuotesLongStderr(t *testing.T) {
+	script := `i=0; while [ $i -lt 300 ]; do echo "line $i" >&2; i=$((i+1)); done; exit 1`
+
+	_, err := newShell(script, time.Minute).LoadQuotes()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), ": stderr: ...") || !strings.HasSuffix(err.Error(), "line 299") {
+		t.Errorf("expected the end of the stderr, got %s", err)
+	}
+	if strings.Contains(err.Error(), "line 0\n") {
+		t.Errorf("expected the beginning of the stderr to be truncated")
+	}
+}
+
+func TestNew(t *testing.T) {
+	loader, err := New("Test", "IT0000000001", security.Params{"command": "python3  loaders/myfund.py --fund 123", "timeout": "30s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"python3", "loaders/myfund.py", "--fund", "123"}; !reflect.DeepEqual(loader.command, expected) {
+		t.Errorf("expected the command %q, got %q", expected, loader.command)
+	}
+	if loader.timeout != 30*time.Second {
+		t.Errorf("expected a timeout of 30s, got %s", loader.timeout)
+	}
+
+	loader, err = New("Test", "IT0000000001", security.Params{"command": "./loader"})
+	if err != nil || loader.timeout != defaultTimeout {
+		t.Errorf("expected the default timeout, got %v (%v)", loader, err)
+	}
+
+	tests := []struct {
+		params security.Params
+		err    string
+	}{
+		{params: security.Params{}, err: "missing 'command' param"},
+		{params: security.Params{"command": "  "}, err: "missing 'command' param"},
+		{params: security.Params{"command": "./loader", "timeout": "60"}, err: "invalid timeout '60'"},
+		{params: security.Params{"command": "./loader", "timeout": "-1s"}, err: "invalid timeout '-1s'"},
+	}
+
+	for _, tt := range tests {
+		_, err := New("Test", "IT0000000001", tt.params)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("expected error '%s', got %v", tt.err, err)
+		}
+	}
+}
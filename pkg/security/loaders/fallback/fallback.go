@@ -0,0 +1,130 @@
+package fallback
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/calendar"
+)
+
+// Source is a loader of the chain, with the name used in the logs and in the index.
+type Source struct {
+	Name string
+	security.QuoteLoader
+}
+
+// Fallback loads the quotes from an ordered list of sources: the first source returning
+// some quotes serves the load, unless the sources are merged to fill each other's gaps.
+type Fallback struct {
+	name    string
+	isin    string
+	sources []Source
+	merge   bool
+
+	// served are the sources that served the last load
+	served []string
+}
+
+// New returns the loader trying the sources in order. With merge all the sources are
+// loaded, and the quotes of the first sources win over the others on the same day.
+// The venue of the key is not part of the ISIN (i.e. "IT0005217770.MOT"), as the
+// sources can be listed on different venues.
+func New(name, isin string, sources []Source, merge bool) (*Fallback, error) {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no sources")
+	}
+
+	isin, _, _ = strings.Cut(isin, ".")
+
+	return &Fallback{
+		name:    name,
+		isin:    isin,
+		sources: sources,
+		merge:   merge,
+	}, nil
+}
+
+func (f *Fallback) Name() string { return f.name }
+
+func (f *Fallback) ISIN() string { return f.isin }
+
+// Sources returns the sources that served the last load.
+func (f *Fallback) Sources() []string {
+	return f.served
+}
+
+func (f *Fallback) LoadQuotes() ([]security.Quote, error) {
+	return f.load(func(s Source) ([]security.Quote, error) {
+		return s.LoadQuotes()
+	})
+}
+
+// LoadQuotesSince loads the quotes after the date from the sources supporting it,
+// and all the quotes from the other ones.
+func (f *Fallback) LoadQuotesSince(since time.Time) ([]security.Quote, error) {
+	return f.load(func(s Source) ([]security.Quote, error) {
+		if sinceLoader, ok := s.QuoteLoader.(security.SinceLoader); ok {
+			return sinceLoader.LoadQuotesSince(since)
+		}
+		return s.LoadQuotes()
+	})
+}
+
+// Backfill loads the full history from the sources supporting it, and all the
+// quotes from the other ones.
+func (f *Fallback) Backfill() ([]security.Quote, error) {
+	return f.load(func(s Source) ([]security.Quote, error) {
+		if backfiller, ok := s.QuoteLoader.(security.Backfiller); ok {
+			return backfiller.Backfill()
+		}
+		return s.LoadQuotes()
+	})
+}
+
+func (f *Fallback) load(loadFn func(s Source) ([]security.Quote, error)) ([]security.Quote, error) {
+	f.served = nil
+
+	var errs error
+	quotesMap := map[time.Time]security.Quote{}
+
+	for _, source := range f.sources {
+		quotes, err := loadFn(source)
+		if err == nil && len(quotes) == 0 {
+			err = fmt.Errorf("no quotes found")
+		}
+		if err != nil {
+			log.Warnf("[%s] source '%s' failed: %s", f.isin, source.Name, err)
+			errs = errors.Join(errs, fmt.Errorf("source '%s': %w", source.Name, err))
+			continue
+		}
+
+		log.Infof("[%s] loaded %d quotes from source '%s'", f.isin, len(quotes), source.Name)
+		f.served = append(f.served, source.Name)
+
+		// the quotes of the previous sources are kept, the others only fill the gaps
+		for _, q := range quotes {
+			day := calendar.UTCDay(q.Date)
+			if _, found := quotesMap[day]; !found {
+				quotesMap[day] = q
+			}
+		}
+
+		if !f.merge {
+			break
+		}
+	}
+
+	if len(f.served) == 0 {
+		return nil, fmt.Errorf("all the sources failed: %w", errs)
+	}
+
+	quotes := make([]security.Quote, 0, len(quotesMap))
+	for _, q := range quotesMap {
+		quotes = append(quotes, q)
+	}
+	return security.Merge(nil, quotes), nil
+}
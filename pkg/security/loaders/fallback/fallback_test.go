@@ -0,0 +1,243 @@
+package fallback
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+)
+
+// fakeLoader returns the quotes or the error, and records the loads
+type fakeLoader struct {
+	quotes []security.Quote
+	err    error
+	calls  []string
+}
+
+func (f *fakeLoader) Name() string { return "fake" }
+func (f *fakeLoader) ISIN() string { return "IT0005217770" }
+
+func (f *fakeLoader) LoadQuotes() ([]security.Quote, error) {
+	f.calls = append(f.calls, "load")
+	return f.quotes, f.err
+}
+
+// fakeSinceLoader also supports the incremental loads and the backfill
+type fakeSinceLoader struct {
+	fakeLoader
+	since time.Time
+}
+
+func (f *fakeSinceLoader) LoadQuotesSince(since time.Time) ([]security.Quote, error) {
+	f.calls = append(f.calls, "since")
+	f.since = since
+	return f.quotes, f.err
+}
+
+func (f *fakeSinceLoader) Backfill() ([]security.Quote, error) {
+	f.calls = append(f.calls, "backfill")
+	return f.quotes, f.err
+}
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func quote(d int, close float32) security.Quote {
+	return security.Quote{Date: day(2024, 1, d), Close: close}
+}
+
+func TestLoadQuotes(t *testing.T) {
+	tests := []struct {
+		name    string
+		loaders []*fakeLoader
+		merge   bool
+		quotes  []security.Quote
+		served  []string
+		calls   []int
+	}{
+		{
+			name: "first success wins",
+			loaders: []*fakeLoader{
+				{quotes: []security.Quote{quote(3, 10), quote(2, 9)}},
+				{quotes: []security.Quote{quote(4, 11)}},
+			},
+			quotes: []security.Quote{quote(2, 9), quote(3, 10)},
+			served: []string{"s0"},
+			calls:  []int{1, 0},
+		},
+		{
+			name: "failed and empty sources are skipped",
+			loaders: []*fakeLoader{
+				{err: errors.New("timeout")},
+				{quotes: []security.Quote{}},
+				{quotes: []security.Quote{quote(2, 9)}},
+			},
+			quotes: []security.Quote{quote(2, 9)},
+			served: []string{"s2"},
+			calls:  []int{1, 1, 1},
+		},
+		{
+			name:  "merge keeps the earlier sources on the same day",
+			merge: true,
+			loaders: []*fakeLoader{
+				{quotes: []security.Quote{quote(2, 9), quote(4, 11)}},
+				{err: errors.New("timeout")},
+				{quotes: []security.Quote{
+					quote(2, 90),
+					// the timestamps are on the same UTC day
+					{Date: time.Date(2024, 1, 4, 17, 30, 0, 0, time.UTC), Close: 110},
+					quote(3, 10),
+					quote(5, 12),
+				}},
+			},
+			quotes: []security.Quote{quote(2, 9), quote(3, 10), quote(4, 11), quote(5, 12)},
+			served: []string{"s0", "s2"},
+			calls:  []int{1, 1, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources := []Source{}
+			for i, l := range tt.loaders {
+				sources = append(sources, Source{Name: fmt.Sprintf("s%d", i), QuoteLoader: l})
+			}
+
+			f, err := New("Test", "IT0005217770.MOT", sources, tt.merge)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			quotes, err := f.LoadQuotes()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(quotes, tt.quotes) {
+				t.Errorf("unexpected quotes:\nexpected %v\ngot      %v", tt.quotes, quotes)
+			}
+			if !reflect.DeepEqual(f.Sources(), tt.served) {
+				t.Errorf("expected the sources %v, got %v", tt.served, f.Sources())
+			}
+			for i, l := range tt.loaders {
+				if len(l.calls) != tt.calls[i] {
+					t.Errorf("expected %d loads of source %d, got %d", tt.calls[i], i, len(l.calls))
+				}
+			}
+		})
+	}
+}
+
+func TestLoadQuotesAllFailed(t *testing.T) {
+	first := &fakeLoader{err: errors.New("connection refused")}
+	second := &fakeLoader{quotes: []security.Quote{quote(2, 9)}}
+
+	f, err := New("Test", "IT0005217770", []Source{
+		{Name: "borsaitaliana", QuoteLoader: first},
+		{Name: "eurotlx", QuoteLoader: second},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.LoadQuotes(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if served := f.Sources(); !reflect.DeepEqual(served, []string{"eurotlx"}) {
+		t.Fatalf("expected the eurotlx source, got %v", served)
+	}
+
+	// the sources of the previous load are reset
+	second.quotes = nil
+	second.err = nil
+
+	_, err = f.LoadQuotes()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, expected := range []string{
+		"all the sources failed",
+		"source 'borsaitaliana': connection refused",
+		"source 'eurotlx': no quotes found",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected '%s' in the error, got %s", expected, err)
+		}
+	}
+	if served := f.Sources(); len(served) != 0 {
+		t.Errorf("expected no sources, got %v", served)
+	}
+}
+
+func TestLoadQuotesSinceAndBackfill(t *testing.T) {
+	since := day(2024, 1, 2)
+
+	tests := []struct {
+		name string
+		load func(f *Fallback) ([]security.Quote, error)
+		call string
+	}{
+		{
+			name: "since",
+			load: func(f *Fallback) ([]security.Quote, error) { return f.LoadQuotesSince(since) },
+			call: "since",
+		},
+		{
+			name: "backfill",
+			load: func(f *Fallback) ([]security.Quote, error) { return f.Backfill() },
+			call: "backfill",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sinceLoader := &fakeSinceLoader{fakeLoader: fakeLoader{quotes: []security.Quote{quote(3, 10)}}}
+			loader := &fakeLoader{quotes: []security.Quote{quote(2, 9)}}
+
+			f, err := New("Test", "IT0005217770", []Source{
+				{Name: "since", QuoteLoader: sinceLoader},
+				{Name: "full", QuoteLoader: loader},
+			}, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			quotes, err := tt.load(f)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if expected := []security.Quote{quote(2, 9), quote(3, 10)}; !reflect.DeepEqual(quotes, expected) {
+				t.Errorf("unexpected quotes:\nexpected %v\ngot      %v", expected, quotes)
+			}
+
+			// the sources without the interface fall back to a full load
+			if !reflect.DeepEqual(sinceLoader.calls, []string{tt.call}) {
+				t.Errorf("expected a '%s' call, got %v", tt.call, sinceLoader.calls)
+			}
+			if !reflect.DeepEqual(loader.calls, []string{"load"}) {
+				t.Errorf("expected a full load, got %v", loader.calls)
+			}
+			if tt.call == "since" && !sinceLoader.since.Equal(since) {
+				t.Errorf("expected since %s, got %s", since, sinceLoader.since)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New("Test", "IT0005217770", nil, false); err == nil || err.Error() != "no sources" {
+		t.Errorf("expected error 'no sources', got %v", err)
+	}
+
+	f, err := New("Test", "IT0005217770.MOT", []Source{{Name: "s0", QuoteLoader: &fakeLoader{}}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.ISIN() != "IT0005217770" {
+		t.Errorf("expected the ISIN without the venue, got '%s'", f.ISIN())
+	}
+}
@@ -4,29 +4,44 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/calendar"
 	"github.com/gocolly/colly/v2"
 )
 
+const defaultCurrency = "EUR"
+
 type FondiDoc struct {
-	name string
-	isin string
+	name     string
+	isin     string
+	currency string
 }
 
-func New(name, isin string) *FondiDoc {
+// New returns the loader of the fund, with the quotes in the currency of
+// the 'currency' param (default EUR).
+func New(name, isin string, params security.Params) *FondiDoc {
+	currency := strings.ToUpper(params["currency"])
+	if currency == "" {
+		currency = defaultCurrency
+	}
+
 	return &FondiDoc{
-		name: name,
-		isin: isin,
+		name:     name,
+		isin:     isin,
+		currency: currency,
 	}
 }
 
 type FondiDocData struct {
 	Description string       `json:"desc"`
 	Currency    string       `json:"cur"`
-	Data        [][2]float32 `json:"data"`
+	Data        [][2]float64 `json:"data"`
 }
 
 func (f *FondiDoc) Name() string {
@@ -39,21 +54,17 @@ func (f *FondiDoc) ISIN() string {
 
 // LoadQuotes implements security.Fund
 func (f *FondiDoc) LoadQuotes() ([]security.Quote, error) {
-	c := colly.NewCollector()
-
-	var fidacode string
-	c.OnHTML("a[fidacode]", func(e *colly.HTMLElement) {
-		fidacode = e.Attr("fidacode")
-	})
-
-	if err := c.Visit("https://www.fondidoc.it/Ricerca/Res?txt=" + f.isin); err != nil {
+	fidacode, err := f.searchFidacode()
+	if err != nil {
 		return nil, err
 	}
 
-	res, err := http.Get("https://www.fondidoc.it/Chart/ChartData?ids=" + fidacode + "&cur=EUR")
+	res, err := http.Get("https://www.fondidoc.it/Chart/ChartData?ids=" + fidacode + "&cur=" + f.currency)
 	if err != nil {
 		return nil, fmt.Errorf("error getting quotes: %w", err)
 	}
+	defer res.Body.Close()
+
 	if res.StatusCode >= 400 {
 		return nil, fmt.Errorf("error from request: status_code %d", res.StatusCode)
 	}
@@ -73,13 +84,56 @@ func (f *FondiDoc) LoadQuotes() ([]security.Quote, error) {
 		return nil, fmt.Errorf("error getting FondidocData from fidacode: %s", fidacode)
 	}
 
+	if !strings.EqualFold(fondiDocData.Currency, f.currency) {
+		return nil, fmt.Errorf("unexpected currency '%s', expected '%s'", fondiDocData.Currency, f.currency)
+	}
+
 	quotes := []security.Quote{}
 	for _, quote := range fondiDocData.Data {
 		quotes = append(quotes, security.Quote{
-			Date:  time.Unix(int64(quote[0])*100, 0),
-			Close: quote[1],
+			Date:  parseDate(quote[0]),
+			Close: float32(quote[1]),
 		})
 	}
 
 	return quotes, nil
 }
+
+// searchFidacode returns the fidacode of the fund, searching it by ISIN
+func (f *FondiDoc) searchFidacode() (string, error) {
+	c := colly.NewCollector()
+
+	fidacodes := []string{}
+	c.OnHTML("a[fidacode]", func(e *colly.HTMLElement) {
+		fidacode := strings.TrimSpace(e.Attr("fidacode"))
+		if fidacode != "" && !slices.Contains(fidacodes, fidacode) {
+			fidacodes = append(fidacodes, fidacode)
+		}
+	})
+
+	if err := c.Visit("https://www.fondidoc.it/Ricerca/Res?txt=" + f.isin); err != nil {
+		return "", fmt.Errorf("error searching fidacode: %w", err)
+	}
+
+	switch len(fidacodes) {
+	case 0:
+		return "", fmt.Errorf("no fidacode found for ISIN %s", f.isin)
+	case 1:
+		return fidacodes[0], nil
+	}
+	return "", fmt.Errorf("multiple fidacodes found for ISIN %s: %s", f.isin, strings.Join(fidacodes, ", "))
+}
+
+// parseDate returns the calendar day of the timestamp, at midnight UTC.
+// The timestamps are in hundreds of seconds, or in milliseconds, since the epoch.
+func parseDate(timestamp float64) time.Time {
+	var t time.Time
+	if timestamp > 1e11 {
+		t = time.UnixMilli(int64(math.Round(timestamp)))
+	} else {
+		t = time.Unix(int64(math.Round(timestamp*100)), 0)
+	}
+
+	// the dates are at midnight of the NAV day, in the Italian timezone
+	return calendar.BorsaItaliana.Day(t)
+}
@@ -7,23 +7,45 @@ import (
 	"time"
 
 	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/calendar"
 	"github.com/gocolly/colly/v2"
 )
 
-const (
-	FonTeDinamicoURL = "https://www.fondofonte.it/gestione-finanziaria/i-valori-quota-dei-comparti/comparto-dinamico/"
-)
+// FonTeCompartiURL is the index page linking the pages of the comparti
+var FonTeCompartiURL = "https://www.fondofonte.it/gestione-finanziaria/i-valori-quota-dei-comparti/"
+
+// comparti maps the comparto param to the title of its page
+var comparti = map[string]string{
+	"conservativo": "Conservativo",
+	"bilanciato":   "Bilanciato",
+	"crescita":     "Crescita",
+	"dinamico":     "Dinamico",
+	"garantito":    "Garantito",
+}
 
 type Fonte struct {
-	name string
-	isin string
+	name     string
+	isin     string
+	comparto string
 }
 
-func New(name, isin string) *Fonte {
-	return &Fonte{
-		name: name,
-		isin: isin,
+// New returns the loader of the Fon.Te. comparto set in the 'comparto' param
+// (conservativo, bilanciato, crescita, dinamico or garantito).
+func New(name, isin string, params security.Params) (*Fonte, error) {
+	comparto := strings.ToLower(params["comparto"])
+	if comparto == "" {
+		return nil, fmt.Errorf("missing 'comparto' param")
 	}
+
+	if _, found := comparti[comparto]; !found {
+		return nil, fmt.Errorf("unknown comparto '%s'", comparto)
+	}
+
+	return &Fonte{
+		name:     name,
+		isin:     isin,
+		comparto: comparto,
+	}, nil
 }
 
 func (e *Fonte) Name() string {
@@ -34,7 +56,41 @@ func (e *Fonte) ISIN() string {
 	return e.isin
 }
 
+// compartoURL returns the URL of the page of the comparto, linked from the index page
+// with its title (i.e. "Comparto Dinamico")
+func (f *Fonte) compartoURL() (string, error) {
+	c := colly.NewCollector()
+
+	comparto := strings.ToLower(comparti[f.comparto])
+	var url string
+
+	c.OnHTML("a[href]", func(e *colly.HTMLElement) {
+		if url != "" {
+			return
+		}
+
+		text := strings.ToLower(strings.Join(strings.Fields(e.Text), " "))
+		if text == comparto || text == "comparto "+comparto {
+			url = e.Request.AbsoluteURL(e.Attr("href"))
+		}
+	})
+
+	if err := c.Visit(FonTeCompartiURL); err != nil {
+		return "", fmt.Errorf("error visiting '%s': %w", FonTeCompartiURL, err)
+	}
+
+	if url == "" {
+		return "", fmt.Errorf("comparto '%s' not linked from '%s'", comparti[f.comparto], FonTeCompartiURL)
+	}
+	return url, nil
+}
+
 func (f *Fonte) LoadQuotes() ([]security.Quote, error) {
+	url, err := f.compartoURL()
+	if err != nil {
+		return nil, err
+	}
+
 	c := colly.NewCollector()
 
 	type yearContent struct {
@@ -43,13 +99,24 @@ func (f *Fonte) LoadQuotes() ([]security.Quote, error) {
 		values []string
 	}
 	years := []yearContent{}
+	// blocks is the number of data blocks, that must match the year headers
+	blocks := 0
+
+	var title string
+	c.OnHTML("title", func(e *colly.HTMLElement) {
+		title = e.Text
+	})
 
 	c.OnHTML("article.content-text-page", func(e *colly.HTMLElement) {
 		e.ForEach("h5.toggle-acf", func(i int, e *colly.HTMLElement) {
-			years = append(years, yearContent{year: e.Text})
+			years = append(years, yearContent{year: strings.TrimSpace(e.Text)})
 		})
 
 		e.ForEach("div.toggle-content-acf", func(i int, e *colly.HTMLElement) {
+			blocks++
+			if i >= len(years) {
+				return
+			}
 			year := years[i]
 
 			e.ForEach("span", func(spanIndex int, e *colly.HTMLElement) {
@@ -71,25 +138,51 @@ func (f *Fonte) LoadQuotes() ([]security.Quote, error) {
 		})
 	})
 
-	c.Visit(FonTeDinamicoURL)
+	if err := c.Visit(url); err != nil {
+		return nil, fmt.Errorf("error visiting '%s': %w", url, err)
+	}
+
+	// check that the page is the one of the comparto
+	comparto := comparti[f.comparto]
+	if !strings.Contains(strings.ToLower(title), strings.ToLower(comparto)) {
+		return nil, fmt.Errorf("page title '%s' does not match comparto '%s'", strings.TrimSpace(title), comparto)
+	}
+
+	if blocks != len(years) {
+		return nil, fmt.Errorf("misaligned page: found %d years and %d value blocks", len(years), blocks)
+	}
 
 	reverse(years)
 
 	quotes := []security.Quote{}
 
 	for _, y := range years {
+		if len(y.months) != len(y.values) {
+			return nil, fmt.Errorf(
+				"misaligned values for year %s: found %d months and %d values",
+				y.year, len(y.months), len(y.values),
+			)
+		}
+
 		for i := range y.months {
-			dateString := fmt.Sprintf("%s %s", y.year, convertMonth(y.months[i]))
-			tt, err := time.Parse("2006 January", dateString)
+			month, found := convertMonth(y.months[i])
+			if !found {
+				return nil, fmt.Errorf("invalid month '%s' for year %s", y.months[i], y.year)
+			}
+
+			year, err := strconv.Atoi(y.year)
 			if err != nil {
-				panic(err)
+				return nil, fmt.Errorf("invalid year '%s': %w", y.year, err)
 			}
-			tt = tt.AddDate(0, 1, -1)
+			dateString := fmt.Sprintf("%s %d", month, year)
+
+			// the monthly NAV is valued on the last business day of the month
+			tt := calendar.TARGET2.LastBusinessDay(year, month)
 
-			y.values[i] = strings.ReplaceAll(y.values[i], ",", ".")
-			closeQuote, err := strconv.ParseFloat(y.values[i], 32)
+			value := strings.ReplaceAll(y.values[i], ",", ".")
+			closeQuote, err := strconv.ParseFloat(value, 32)
 			if err != nil {
-				panic(err)
+				return nil, fmt.Errorf("invalid value '%s' for %s: %w", y.values[i], dateString, err)
 			}
 
 			quotes = append(quotes, security.Quote{
@@ -108,32 +201,32 @@ func reverse[S ~[]E, E any](s S) {
 	}
 }
 
-func convertMonth(month string) time.Month {
+func convertMonth(month string) (time.Month, bool) {
 	switch month {
 	case "Gennaio":
-		return time.January
+		return time.January, true
 	case "Febbraio":
-		return time.February
+		return time.February, true
 	case "Marzo":
-		return time.March
+		return time.March, true
 	case "Aprile":
-		return time.April
+		return time.April, true
 	case "Maggio":
-		return time.May
+		return time.May, true
 	case "Giugno":
-		return time.June
+		return time.June, true
 	case "Luglio":
-		return time.July
+		return time.July, true
 	case "Agosto":
-		return time.August
+		return time.August, true
 	case "Settembre":
-		return time.September
+		return time.September, true
 	case "Ottobre":
-		return time.October
+		return time.October, true
 	case "Novembre":
-		return time.November
+		return time.November, true
 	case "Dicembre":
-		return time.December
+		return time.December, true
 	}
-	return time.January
+	return time.January, false
 }
@@ -0,0 +1,100 @@
+package fonte
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+)
+
+// newTestServer serves the index page and the pages of the comparti of the testdata directory
+func newTestServer(t *testing.T) {
+	t.Helper()
+
+	ts := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	t.Cleanup(ts.Close)
+
+	previousURL := FonTeCompartiURL
+	FonTeCompartiURL = ts.URL + "/"
+	t.Cleanup(func() { FonTeCompartiURL = previousURL })
+}
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestLoadQuotes(t *testing.T) {
+	newTestServer(t)
+
+	loader, err := New("Test", "FP-FonTe-Dinamico", security.Params{"comparto": "dinamico"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quotes, err := loader.LoadQuotes()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// the values of each year header are paired with the block that follows it,
+	// on the last business day of the month
+	expected := []security.Quote{
+		{Date: day(2023, time.November, 30), Close: 20.5},
+		{Date: day(2023, time.December, 29), Close: 20.987},
+		{Date: day(2024, time.January, 31), Close: 21.123},
+		{Date: day(2024, time.February, 29), Close: 21.456},
+	}
+	if !reflect.DeepEqual(quotes, expected) {
+		t.Errorf("unexpected quotes:\nexpected %v\ngot      %v", expected, quotes)
+	}
+}
+
+func TestLoadQuotesErrors(t *testing.T) {
+	newTestServer(t)
+
+	tests := []struct {
+		comparto string
+		err      string
+	}{
+		{comparto: "bilanciato", err: "misaligned page: found 2 years and 1 value blocks"},
+		{comparto: "crescita", err: "page title 'Comparto Dinamico - Fondo Fon.Te.' does not match comparto 'Crescita'"},
+		{comparto: "garantito", err: "comparto 'Garantito' not linked from"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.comparto, func(t *testing.T) {
+			loader, err := New("Test", "FP-FonTe", security.Params{"comparto": tt.comparto})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = loader.LoadQuotes()
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error '%s', got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		params security.Params
+		err    string
+	}{
+		{params: security.Params{}, err: "missing 'comparto' param"},
+		{params: security.Params{"comparto": "azionario"}, err: "unknown comparto 'azionario'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err, func(t *testing.T) {
+			_, err := New("Test", "FP-FonTe", tt.params)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error '%s', got %v", tt.err, err)
+			}
+		})
+	}
+}
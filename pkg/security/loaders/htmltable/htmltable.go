@@ -0,0 +1,188 @@
+package htmltable
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/locale"
+	"github.com/gocolly/colly/v2"
+)
+
+const (
+	defaultRowsSelector  = "table tbody tr"
+	defaultCellsSelector = "td"
+	defaultDateCell      = "1"
+	defaultCloseCell     = "2"
+	defaultDateLayout    = "02/01/2006"
+	defaultLocale        = "en"
+	defaultMinRows       = 1
+)
+
+// HTMLTable scrapes the quotes from the rows of an HTML page, configured with the params of the security.
+type HTMLTable struct {
+	name string
+	isin string
+
+	url        string
+	rows       string
+	cells      string
+	dateCell   string
+	closeCell  string
+	next       string
+	dateLayout string
+	locale     *locale.Locale
+	minRows    int
+}
+
+// New returns the loader of the security. The params are:
+//   - url: the URL of the page, where '{isin}' and '{code}' are replaced by the ISIN and the 'code' param
+//   - rows: the selector of the rows (default 'table tbody tr')
+//   - cells: the selector of the cells in the rows (default 'td')
+//   - dateCell, closeCell: the 1-based numbers of the cells, or the selectors of the values in the row (default 1 and 2)
+//   - dateLayout: the Go layout of the dates (default '02/01/2006')
+//   - locale: the locale of the month names and numbers (default 'en')
+//   - next: the selector of the link to the next page, if the table is paginated
+//   - minRows: the minimum number of quotes expected, to detect the changes of the layout (default 1)
+func New(name, isin string, params security.Params) (*HTMLTable, error) {
+	h := &HTMLTable{
+		name:       name,
+		isin:       isin,
+		rows:       params["rows"],
+		cells:      params["cells"],
+		dateCell:   params["dateCell"],
+		closeCell:  params["closeCell"],
+		next:       params["next"],
+		dateLayout: params["dateLayout"],
+		minRows:    defaultMinRows,
+	}
+
+	if params["url"] == "" {
+		return nil, fmt.Errorf("missing 'url' param")
+	}
+	h.url = strings.NewReplacer(
+		"{isin}", url.PathEscape(isin),
+		"{code}", url.PathEscape(params["code"]),
+	).Replace(params["url"])
+
+	if h.rows == "" {
+		h.rows = defaultRowsSelector
+	}
+	if h.cells == "" {
+		h.cells = defaultCellsSelector
+	}
+	if h.dateCell == "" {
+		h.dateCell = defaultDateCell
+	}
+	if h.closeCell == "" {
+		h.closeCell = defaultCloseCell
+	}
+	if h.dateLayout == "" {
+		h.dateLayout = defaultDateLayout
+	}
+
+	localeName := params["locale"]
+	if localeName == "" {
+		localeName = defaultLocale
+	}
+	l, err := locale.Get(localeName)
+	if err != nil {
+		return nil, err
+	}
+	h.locale = l
+
+	if minRows := params["minRows"]; minRows != "" {
+		h.minRows, err = strconv.Atoi(minRows)
+		if err != nil || h.minRows < 0 {
+			return nil, fmt.Errorf("invalid minRows '%s'", minRows)
+		}
+	}
+
+	return h, nil
+}
+
+func (h *HTMLTable) Name() string { return h.name }
+
+func (h *HTMLTable) ISIN() string { return h.isin }
+
+// LoadQuotes loads the quotes from the rows of the page, following the pagination links
+// if the 'next' selector is set.
+func (h *HTMLTable) LoadQuotes() ([]security.Quote, error) {
+	c := colly.NewCollector()
+
+	quotes := []security.Quote{}
+	var visitErr error
+
+	c.OnHTML(h.rows, func(e *colly.HTMLElement) {
+		dateString, closeString := h.cell(e, h.dateCell), h.cell(e, h.closeCell)
+		if dateString == "" || closeString == "" {
+			log.Debugf("[%s] skipping row %d of '%s': missing cells", h.isin, e.Index, e.Request.URL)
+			return
+		}
+
+		date, err := h.locale.ParseDate(h.dateLayout, dateString)
+		if err != nil {
+			log.Debugf("[%s] skipping row %d of '%s': invalid date '%s'", h.isin, e.Index, e.Request.URL, dateString)
+			return
+		}
+
+		closeQuote, err := h.locale.ParseFloat(closeString)
+		if err != nil {
+			log.Debugf("[%s] skipping row %d of '%s': %s", h.isin, e.Index, e.Request.URL, err)
+			return
+		}
+
+		quotes = append(quotes, security.Quote{
+			Date:  date,
+			Close: float32(closeQuote),
+		})
+	})
+
+	if h.next != "" {
+		// the already visited pages are skipped by the collector
+		c.OnHTML(h.next, func(e *colly.HTMLElement) {
+			next := e.Request.AbsoluteURL(e.Attr("href"))
+			if next == "" {
+				return
+			}
+
+			log.Debugf("visiting next page '%s'", next)
+			if err := e.Request.Visit(next); err != nil && !errors.Is(err, colly.ErrAlreadyVisited) {
+				visitErr = errors.Join(visitErr, fmt.Errorf("error visiting '%s': %w", next, err))
+			}
+		})
+	}
+
+	if err := c.Visit(h.url); err != nil {
+		return nil, fmt.Errorf("error visiting '%s': %w", h.url, err)
+	}
+	if visitErr != nil {
+		return nil, visitErr
+	}
+
+	if len(quotes) < h.minRows {
+		return nil, fmt.Errorf("found %d quotes in '%s', expected at least %d: the layout of the page could be changed", len(quotes), h.url, h.minRows)
+	}
+
+	// the pages can overlap, Merge removes the duplicated dates and sorts the quotes
+	return security.Merge(nil, quotes), nil
+}
+
+// cell returns the text of the cell of the row, from its 1-based number among the
+// cells or from its selector
+func (h *HTMLTable) cell(e *colly.HTMLElement, cell string) string {
+	n, err := strconv.Atoi(cell)
+	if err != nil {
+		return strings.TrimSpace(e.ChildText(cell))
+	}
+
+	cells := e.ChildTexts(h.cells)
+	if n < 1 || n > len(cells) {
+		return ""
+	}
+	return strings.TrimSpace(cells[n-1])
+}
@@ -0,0 +1,232 @@
+package htmltable
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+)
+
+const testISIN = "IT0000000001"
+
+// newTestServer serves the files of the testdata directory, and records the requested paths
+func newTestServer(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+
+	requested := []string{}
+	files := http.FileServer(http.Dir("testdata"))
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.RequestURI())
+		files.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts, &requested
+}
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestLoadQuotes(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		params string
+		quotes []security.Quote
+	}{
+		{
+			name:   "single page",
+			params: "url=" + ts.URL + "/page3.html",
+			quotes: []security.Quote{
+				{Date: day(2024, 1, 2), Close: 101.5},
+			},
+		},
+		{
+			// the pages overlap and link back to the first one
+			name:   "paginated table",
+			params: "url=" + ts.URL + "/page1.html&next=a.next",
+			quotes: []security.Quote{
+				{Date: day(2024, 1, 2), Close: 101.5},
+				{Date: day(2024, 1, 3), Close: 101.75},
+				{Date: day(2024, 1, 4), Close: 102},
+				{Date: day(2024, 1, 5), Close: 102.25},
+				{Date: day(2024, 1, 8), Close: 102.5},
+			},
+		},
+		{
+			name:   "pagination not followed without the next selector",
+			params: "url=" + ts.URL + "/page1.html",
+			quotes: []security.Quote{
+				{Date: day(2024, 1, 4), Close: 102},
+				{Date: day(2024, 1, 5), Close: 102.25},
+				{Date: day(2024, 1, 8), Close: 102.5},
+			},
+		},
+		{
+			// the header and the rows with an invalid close are skipped
+			name:   "cell selectors",
+			params: "url=" + ts.URL + "/cells.html&rows=div.nav-history div.row&dateCell=span.date&closeCell=span.nav",
+			quotes: []security.Quote{
+				{Date: day(2024, 1, 2), Close: 12.5},
+				{Date: day(2024, 1, 3), Close: 12.48},
+			},
+		},
+		{
+			name:   "cell numbers with a custom cells selector",
+			params: "url=" + ts.URL + "/cells.html&rows=div.nav-history div.row&cells=span&dateCell=3&closeCell=2",
+			quotes: []security.Quote{
+				{Date: day(2024, 1, 2), Close: 12.5},
+				{Date: day(2024, 1, 3), Close: 12.48},
+			},
+		},
+		{
+			name:   "italian locale",
+			params: "url=" + ts.URL + "/italian.html&rows=table.quote tr&closeCell=3&dateLayout=2 Jan 2006&locale=it",
+			quotes: []security.Quote{
+				{Date: day(2024, 1, 2), Close: 1234.56},
+				{Date: day(2024, 1, 3), Close: 1233.9},
+				{Date: day(2024, 2, 29), Close: 1240},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := security.ParseParams(tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			loader, err := New("Test", testISIN, params)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			quotes, err := loader.LoadQuotes()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(quotes, tt.quotes) {
+				t.Errorf("unexpected quotes:\nexpected %v\ngot      %v", tt.quotes, quotes)
+			}
+		})
+	}
+}
+
+// every page is visited once, also when the pages link back to the visited ones
+func TestLoadQuotesPages(t *testing.T) {
+	ts, requested := newTestServer(t)
+
+	loader, err := New("Test", testISIN, security.Params{"url": ts.URL + "/page1.html", "next": "a.next"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loader.LoadQuotes(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{"/page1.html", "/page2.html", "/page3.html"}
+	if !reflect.DeepEqual(*requested, expected) {
+		t.Errorf("expected the requests %v, got %v", expected, *requested)
+	}
+}
+
+func TestLoadQuotesErrors(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		params string
+		err    string
+	}{
+		{
+			name:   "missing page",
+			params: "url=" + ts.URL + "/missing.html",
+			err:    "error visiting",
+		},
+		{
+			name:   "missing next page",
+			params: "url=" + ts.URL + "/broken.html&next=a.next",
+			err:    "missing.html",
+		},
+		{
+			name:   "fewer rows than minRows",
+			params: "url=" + ts.URL + "/layout.html&minRows=2",
+			err:    "found 1 quotes in '" + ts.URL + "/layout.html', expected at least 2: the layout of the page could be changed",
+		},
+		{
+			name:   "no rows",
+			params: "url=" + ts.URL + "/cells.html",
+			err:    "found 0 quotes",
+		},
+		{
+			name:   "no valid dates",
+			params: "url=" + ts.URL + "/page1.html&dateLayout=2006-01-02",
+			err:    "found 0 quotes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := security.ParseParams(tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			loader, err := New("Test", testISIN, params)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			_, err = loader.LoadQuotes()
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error '%s', got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// minRows=0 accepts the pages without quotes
+func TestLoadQuotesNoMinRows(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	loader, err := New("Test", testISIN, security.Params{"url": ts.URL + "/cells.html", "minRows": "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quotes, err := loader.LoadQuotes()
+	if err != nil || len(quotes) != 0 {
+		t.Errorf("expected no quotes, got %v (%v)", quotes, err)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		params security.Params
+		err    string
+	}{
+		{name: "missing url", params: security.Params{}, err: "missing 'url' param"},
+		{name: "unknown locale", params: security.Params{"url": "http://localhost/", "locale": "es"}, err: "unknown locale"},
+		{name: "invalid minRows", params: security.Params{"url": "http://localhost/", "minRows": "-1"}, err: "invalid minRows '-1'"},
+		{name: "minRows not a number", params: security.Params{"url": "http://localhost/", "minRows": "many"}, err: "invalid minRows 'many'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New("Test", testISIN, tt.params)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error '%s', got %v", tt.err, err)
+			}
+		})
+	}
+}
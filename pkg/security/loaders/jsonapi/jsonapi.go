@@ -0,0 +1,361 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/calendar"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/locale"
+)
+
+const (
+	defaultDateFormat = "2006-01-02"
+	defaultLocale     = "en"
+	defaultRangeDays  = 365
+
+	// backfillLimit is the oldest year requested during a backfill
+	backfillLimit = 1990
+
+	// headerPrefix is the prefix of the header params (i.e. 'header.customer')
+	headerPrefix = "header."
+
+	// the date formats of the epoch timestamps
+	unixFormat   = "unix"
+	unixMsFormat = "unixms"
+)
+
+// JSONAPI loads the quotes from a JSON API, configured with the params of the security.
+type JSONAPI struct {
+	name string
+	isin string
+
+	// url and body are the templates of the request, with the range placeholders
+	url         string
+	method      string
+	body        string
+	headers     map[string]string
+	rangeFormat string
+	rangeDays   int
+
+	datePath   []step
+	closePath  []step
+	dateFormat string
+	locale     *locale.Locale
+	calendar   *calendar.Calendar
+}
+
+// New returns the loader of the security. The params are:
+//   - url: the URL of the API, where '{isin}' and '{code}' are replaced by the ISIN and the 'code' param,
+//     and '{from}' (or '{since}') and '{to}' by the range of the requested quotes
+//   - method: the request method (default GET, or POST with a body)
+//   - body: the body of the request, with the same placeholders of the URL
+//   - header.<name>: the value of a header. The names are sent as written, without canonicalization
+//   - rangeFormat: the Go layout of the range placeholders, or 'unix' and 'unixms' (default '2006-01-02')
+//   - rangeDays: the days of the range requested by a load, and of the backfill windows (default 365)
+//   - datePath, closePath: the path expressions of the dates and the closes (i.e. '$.data[*][0]' and '$.data[*][1]')
+//   - dateFormat: the Go layout of the dates, or 'unix' and 'unixms' for the epoch timestamps (default '2006-01-02')
+//   - locale: the locale of the month names and of the numbers written as strings (default 'en')
+//   - calendar: the calendar used for the day of the timestamps (default UTC)
+func New(name, isin string, params security.Params) (*JSONAPI, error) {
+	j := &JSONAPI{
+		name:        name,
+		isin:        isin,
+		method:      strings.ToUpper(params["method"]),
+		headers:     map[string]string{},
+		rangeFormat: params["rangeFormat"],
+		rangeDays:   defaultRangeDays,
+		dateFormat:  params["dateFormat"],
+	}
+
+	if params["url"] == "" {
+		return nil, fmt.Errorf("missing 'url' param")
+	}
+
+	j.url = strings.NewReplacer(
+		"{isin}", url.PathEscape(isin),
+		"{code}", url.PathEscape(params["code"]),
+	).Replace(params["url"])
+
+	j.body = strings.NewReplacer(
+		"{isin}", isin,
+		"{code}", params["code"],
+	).Replace(params["body"])
+
+	if j.method == "" {
+		j.method = http.MethodGet
+		if j.body != "" {
+			j.method = http.MethodPost
+		}
+	}
+
+	for key, value := range params {
+		if name, found := strings.CutPrefix(key, headerPrefix); found && name != "" {
+			j.headers[name] = value
+		}
+	}
+	if params["headers"] != "" {
+		return nil, fmt.Errorf("unsupported 'headers' param: set each header with a 'header.<name>' param")
+	}
+
+	if j.rangeFormat == "" {
+		j.rangeFormat = defaultDateFormat
+	}
+
+	if rangeDays := params["rangeDays"]; rangeDays != "" {
+		days, err := strconv.Atoi(rangeDays)
+		if err != nil || days <= 0 {
+			return nil, fmt.Errorf("invalid rangeDays '%s'", rangeDays)
+		}
+		j.rangeDays = days
+	}
+
+	var err error
+	if j.datePath, err = parsePath(params["datePath"]); err != nil {
+		return nil, err
+	}
+	if j.closePath, err = parsePath(params["closePath"]); err != nil {
+		return nil, err
+	}
+	if len(j.datePath) == 0 || len(j.closePath) == 0 {
+		return nil, fmt.Errorf("missing 'datePath' or 'closePath' param")
+	}
+
+	if j.dateFormat == "" {
+		j.dateFormat = defaultDateFormat
+	}
+
+	localeName := params["locale"]
+	if localeName == "" {
+		localeName = defaultLocale
+	}
+	if j.locale, err = locale.Get(localeName); err != nil {
+		return nil, err
+	}
+
+	if name := params["calendar"]; name != "" {
+		if j.calendar, err = calendar.Get(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return j, nil
+}
+
+func (j *JSONAPI) Name() string { return j.name }
+
+func (j *JSONAPI) ISIN() string { return j.isin }
+
+// LoadQuotes loads the quotes of the last 'rangeDays', if the request has the range placeholders.
+func (j *JSONAPI) LoadQuotes() ([]security.Quote, error) {
+	to := time.Now().UTC()
+	return j.loadRange(to.AddDate(0, 0, -j.rangeDays), to)
+}
+
+// LoadQuotesSince loads the quotes from the since date to today, or all the quotes
+// returned by the API if the request has no range placeholders.
+func (j *JSONAPI) LoadQuotesSince(since time.Time) ([]security.Quote, error) {
+	return j.loadRange(since, time.Now().UTC())
+}
+
+// Backfill loads the full history of the security, walking the windows of 'rangeDays'
+// back until no more quotes are returned. Without the range placeholders the API already
+// returns all the quotes it has.
+func (j *JSONAPI) Backfill() ([]security.Quote, error) {
+	if !j.hasRange() {
+		return j.LoadQuotes()
+	}
+
+	quotes := []security.Quote{}
+
+	to := time.Now().UTC()
+	for to.Year() >= backfillLimit {
+		from := to.AddDate(0, 0, -j.rangeDays)
+
+		windowQuotes, err := j.loadRange(from, to)
+		if err != nil {
+			return nil, err
+		}
+		if len(windowQuotes) == 0 {
+			break
+		}
+
+		quotes = security.Merge(quotes, windowQuotes)
+		to = from
+	}
+
+	return quotes, nil
+}
+
+// hasRange returns true if the request has the placeholders of the range
+func (j *JSONAPI) hasRange() bool {
+	for _, placeholder := range []string{"{from}", "{since}", "{to}"} {
+		if strings.Contains(j.url, placeholder) || strings.Contains(j.body, placeholder) {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *JSONAPI) loadRange(from, to time.Time) ([]security.Quote, error) {
+	fromValue, toValue := j.formatRange(from), j.formatRange(to)
+
+	requestURL := strings.NewReplacer(
+		"{from}", url.PathEscape(fromValue),
+		"{since}", url.PathEscape(fromValue),
+		"{to}", url.PathEscape(toValue),
+	).Replace(j.url)
+
+	var body io.Reader
+	if j.body != "" {
+		body = strings.NewReader(strings.NewReplacer(
+			"{from}", fromValue,
+			"{since}", fromValue,
+			"{to}", toValue,
+		).Replace(j.body))
+	}
+
+	req, err := http.NewRequest(j.method, requestURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if j.body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	// the headers are set directly in the map, because some APIs require
+	// them as written (i.e. the lowercase 'customer' of Raiffeisen)
+	for key, value := range j.headers {
+		req.Header[key] = []string{value}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error doing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("error from request: status_code %d", resp.StatusCode)
+	}
+
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading body: %w", err)
+	}
+
+	return j.parse(respBytes)
+}
+
+// formatRange formats a date of the range with the 'rangeFormat'
+func (j *JSONAPI) formatRange(t time.Time) string {
+	switch j.rangeFormat {
+	case unixFormat:
+		return strconv.FormatInt(t.Unix(), 10)
+	case unixMsFormat:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	}
+	return t.UTC().Format(j.rangeFormat)
+}
+
+func (j *JSONAPI) parse(data []byte) ([]security.Quote, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("error unmarshaling body: %w", err)
+	}
+
+	dates, err := eval(j.datePath, doc)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting the dates: %w", err)
+	}
+	closes, err := eval(j.closePath, doc)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting the closes: %w", err)
+	}
+	if len(dates) != len(closes) {
+		return nil, fmt.Errorf("found %d dates and %d closes", len(dates), len(closes))
+	}
+
+	quotes := []security.Quote{}
+	for i := range dates {
+		date, err := j.parseDate(dates[i])
+		if err != nil {
+			return nil, fmt.Errorf("quote %d: %w", i, err)
+		}
+
+		closeQuote, err := j.parseNumber(closes[i])
+		if err != nil {
+			return nil, fmt.Errorf("quote %d: %w", i, err)
+		}
+
+		quotes = append(quotes, security.Quote{
+			Date:  date,
+			Close: float32(closeQuote),
+		})
+	}
+
+	sort.Slice(quotes, func(i, j int) bool {
+		return quotes[i].Date.Before(quotes[j].Date)
+	})
+
+	return quotes, nil
+}
+
+func (j *JSONAPI) parseDate(value any) (time.Time, error) {
+	var s string
+	switch v := value.(type) {
+	case json.Number:
+		s = v.String()
+	case string:
+		s = v
+	default:
+		return time.Time{}, fmt.Errorf("invalid date %v", value)
+	}
+
+	var t time.Time
+	switch j.dateFormat {
+	case unixFormat, unixMsFormat:
+		epoch, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp '%s'", s)
+		}
+		if j.dateFormat == unixFormat {
+			epoch *= 1000
+		}
+		t = time.UnixMilli(int64(epoch))
+
+	default:
+		var err error
+		t, err = j.locale.ParseDate(j.dateFormat, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date '%s'", s)
+		}
+	}
+
+	if j.calendar != nil {
+		return j.calendar.Day(t), nil
+	}
+	return calendar.UTCDay(t), nil
+}
+
+func (j *JSONAPI) parseNumber(value any) (float64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Float64()
+	case string:
+		return j.locale.ParseFloat(v)
+	}
+	return 0, fmt.Errorf("invalid number %v", value)
+}
@@ -0,0 +1,221 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+)
+
+const testISIN = "CH0025417491"
+
+// request is a request received by the test server
+type request struct {
+	method  string
+	uri     string
+	body    string
+	headers http.Header
+}
+
+// newTestServer serves the quotes in the 'from' and 'to' range of the body, in the Raiffeisen format
+func newTestServer(t *testing.T, quotes map[string]float64) (*httptest.Server, *[]request) {
+	t.Helper()
+
+	requests := []request{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, request{method: r.Method, uri: r.URL.RequestURI(), body: string(body), headers: r.Header})
+
+		var rng struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &rng); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		historyQuotes := []map[string]any{}
+		for date, close := range quotes {
+			if (rng.From == "" || date >= rng.From) && (rng.To == "" || date < rng.To) {
+				historyQuotes = append(historyQuotes, map[string]any{"date": date + "T00:00:00", "close": close})
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"historyQuotes": historyQuotes})
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts, &requests
+}
+
+func newLoader(t *testing.T, params string) *JSONAPI {
+	t.Helper()
+
+	p, err := security.ParseParams(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loader, err := New("Test", testISIN, p)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return loader
+}
+
+func TestLoadQuotesSince(t *testing.T) {
+	ts, requests := newTestServer(t, map[string]float64{
+		"2024-01-02": 10.5,
+		"2024-01-03": 10.75,
+		"2023-12-29": 10,
+	})
+
+	loader := newLoader(t, "url="+ts.URL+"/api/HistoryQuotes?isin={isin}%26from={from}"+
+		`&body={"valor":{code},"from":"{since}","to":"{to}"}&code=2541749`+
+		"&header.customer=raiffeisen-prod&header.Accept=application/json, text/plain"+
+		"&datePath=$.historyQuotes[*].date&closePath=$.historyQuotes[*].close&dateFormat=2006-01-02T15:04:05&calendar=six")
+
+	quotes, err := loader.LoadQuotesSince(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []security.Quote{
+		{Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Close: 10.5},
+		{Date: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), Close: 10.75},
+	}
+	if !reflect.DeepEqual(quotes, expected) {
+		t.Errorf("unexpected quotes:\nexpected %v\ngot      %v", expected, quotes)
+	}
+
+	if len(*requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(*requests))
+	}
+	req := (*requests)[0]
+
+	if req.method != http.MethodPost {
+		t.Errorf("expected a POST request, got %s", req.method)
+	}
+	if expectedURI := "/api/HistoryQuotes?isin=" + testISIN + "&from=2024-01-01"; req.uri != expectedURI {
+		t.Errorf("expected URI '%s', got '%s'", expectedURI, req.uri)
+	}
+
+	today := time.Now().UTC().Format("2006-01-02")
+	if expectedBody := `{"valor":2541749,"from":"2024-01-01","to":"` + today + `"}`; req.body != expectedBody {
+		t.Errorf("expected body '%s', got '%s'", expectedBody, req.body)
+	}
+
+	// the values of the headers can have commas
+	if customer := req.headers.Get("customer"); customer != "raiffeisen-prod" {
+		t.Errorf("expected 'customer' header 'raiffeisen-prod', got '%s'", customer)
+	}
+	if accept := req.headers.Get("Accept"); accept != "application/json, text/plain" {
+		t.Errorf("expected 'Accept' header 'application/json, text/plain', got '%s'", accept)
+	}
+}
+
+func TestLoadQuotesRange(t *testing.T) {
+	ts, requests := newTestServer(t, map[string]float64{})
+
+	loader := newLoader(t, "url="+ts.URL+"/quotes?from={from}%26to={to}&rangeFormat=unix&rangeDays=30"+
+		"&datePath=$.historyQuotes[*].date&closePath=$.historyQuotes[*].close")
+
+	before := time.Now().UTC()
+	if _, err := loader.LoadQuotes(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var from, to int64
+	if _, err := fmt.Sscanf((*requests)[0].uri, "/quotes?from=%d&to=%d", &from, &to); err != nil {
+		t.Fatalf("unexpected URI '%s': %s", (*requests)[0].uri, err)
+	}
+	if to < before.Unix() || to-from != 30*24*60*60 {
+		t.Errorf("expected the last 30 days, got [%d, %d]", from, to)
+	}
+}
+
+func TestBackfill(t *testing.T) {
+	now := time.Now().UTC()
+	quotes := map[string]float64{}
+	for _, d := range []time.Time{now.AddDate(0, 0, -1), now.AddDate(0, 0, -150), now.AddDate(0, 0, -250)} {
+		quotes[d.Format("2006-01-02")] = 10
+	}
+
+	ts, requests := newTestServer(t, quotes)
+
+	loader := newLoader(t, "url="+ts.URL+`&body={"from":"{from}","to":"{to}"}&rangeDays=100`+
+		"&datePath=$.historyQuotes[*].date&closePath=$.historyQuotes[*].close&dateFormat=2006-01-02T15:04:05")
+
+	backfilled, err := loader.Backfill()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(backfilled) != 3 {
+		t.Errorf("expected 3 quotes, got %d", len(backfilled))
+	}
+	// the windows are walked back until an empty one
+	if len(*requests) != 4 {
+		t.Errorf("expected 4 requests, got %d", len(*requests))
+	}
+}
+
+func TestBackfillWithoutRange(t *testing.T) {
+	ts, requests := newTestServer(t, map[string]float64{"2024-01-02": 10})
+
+	loader := newLoader(t, "url="+ts.URL+"&datePath=$.historyQuotes[*].date&closePath=$.historyQuotes[*].close&dateFormat=2006-01-02T15:04:05")
+
+	backfilled, err := loader.Backfill()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(backfilled) != 1 || len(*requests) != 1 {
+		t.Errorf("expected 1 quote from 1 request, got %d quotes from %d requests", len(backfilled), len(*requests))
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		params string
+		err    string
+	}{
+		{
+			name:   "comma separated headers",
+			params: "url=http://localhost&datePath=$.d&closePath=$.c&headers=customer:raiffeisen-prod",
+			err:    "unsupported 'headers' param",
+		},
+		{
+			name:   "invalid rangeDays",
+			params: "url=http://localhost&datePath=$.d&closePath=$.c&rangeDays=0",
+			err:    "invalid rangeDays '0'",
+		},
+		{
+			name:   "missing paths",
+			params: "url=http://localhost&datePath=$.d",
+			err:    "missing 'datePath' or 'closePath' param",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := security.ParseParams(tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = New("Test", testISIN, params)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error '%s', got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package jsonapi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// step is a step of a path expression: a key of an object, an index of an array,
+// or all the elements of an array (wildcard)
+type step struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parsePath parses the JSONPath-like expressions, with the subset of the syntax
+// needed to select the values of a response:
+//
+//	$.data[*].date
+//	$.series[0].values[*][1]
+//	$['quote-list'][*].close
+func parsePath(expr string) ([]step, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(expr), "$")
+	steps := []step{}
+
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			rest = rest[end:]
+
+			switch name {
+			case "":
+				return nil, fmt.Errorf("invalid path '%s': empty key", expr)
+			case "*":
+				steps = append(steps, step{wildcard: true})
+			default:
+				steps = append(steps, step{key: name})
+			}
+
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("invalid path '%s': missing ']'", expr)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+
+			if inner == "*" {
+				steps = append(steps, step{wildcard: true})
+				continue
+			}
+			if unquoted, err := strconv.Unquote(inner); err == nil {
+				steps = append(steps, step{key: unquoted})
+				continue
+			}
+			if len(inner) > 1 && inner[0] == '\'' && inner[len(inner)-1] == '\'' {
+				steps = append(steps, step{key: inner[1 : len(inner)-1]})
+				continue
+			}
+
+			index, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path '%s': invalid index '%s'", expr, inner)
+			}
+			steps = append(steps, step{index: index, isIndex: true})
+
+		default:
+			return nil, fmt.Errorf("invalid path '%s': unexpected '%s'", expr, rest)
+		}
+	}
+
+	return steps, nil
+}
+
+// eval returns the values selected by the steps, in document order. The wildcards
+// are supported only on arrays, to keep the dates and the values aligned.
+func eval(steps []step, value any) ([]any, error) {
+	values := []any{value}
+
+	for i, s := range steps {
+		next := []any{}
+
+		for _, v := range values {
+			switch {
+			case s.wildcard:
+				arr, ok := v.([]any)
+				if !ok {
+					return nil, fmt.Errorf("step %d: wildcard on a %T", i+1, v)
+				}
+				next = append(next, arr...)
+
+			case s.isIndex:
+				arr, ok := v.([]any)
+				if !ok {
+					return nil, fmt.Errorf("step %d: index %d on a %T", i+1, s.index, v)
+				}
+				index := s.index
+				if index < 0 {
+					index += len(arr)
+				}
+				if index < 0 || index >= len(arr) {
+					return nil, fmt.Errorf("step %d: index %d out of range", i+1, s.index)
+				}
+				next = append(next, arr[index])
+
+			default:
+				obj, ok := v.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("step %d: key '%s' on a %T", i+1, s.key, v)
+				}
+				elem, found := obj[s.key]
+				if !found {
+					return nil, fmt.Errorf("step %d: key '%s' not found", i+1, s.key)
+				}
+				next = append(next, elem)
+			}
+		}
+
+		values = next
+	}
+
+	return values, nil
+}
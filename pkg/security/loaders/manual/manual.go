@@ -0,0 +1,192 @@
+package manual
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultDir = "manual"
+	dateFormat = "2006-01-02"
+)
+
+// Manual loads the quotes entered by hand in a local CSV or YAML file, for the
+// securities without a source to scrape (i.e. the funds sending only PDF statements).
+type Manual struct {
+	name string
+	isin string
+	file string
+}
+
+// New returns the loader of the security. The 'file' param is the path of the quotes file,
+// with the '.csv', '.yaml' or '.yml' extension (default 'manual/<ISIN>.csv').
+//
+// The CSV files have a 'date,close' header, as the csv output format:
+//
+//	date,close
+//	2024-01-31,10.52
+//
+// The YAML files have a list of quotes, i.e. '- {date: 2024-01-31, close: 10.52}'.
+func New(name, isin string, params security.Params) (*Manual, error) {
+	file := params["file"]
+	if file == "" {
+		file = filepath.Join(defaultDir, isin+".csv")
+	}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv", ".yaml", ".yml":
+	default:
+		return nil, fmt.Errorf("unsupported file '%s': expected a .csv, .yaml or .yml file", file)
+	}
+
+	return &Manual{
+		name: name,
+		isin: isin,
+		file: file,
+	}, nil
+}
+
+func (m *Manual) Name() string { return m.name }
+
+func (m *Manual) ISIN() string { return m.isin }
+
+// rawQuote is a quote as written in the file, validated by validate
+type rawQuote struct {
+	// line is the line of the quote in the file, for the error messages
+	line  int
+	Date  string `yaml:"date"`
+	Close string `yaml:"close"`
+}
+
+func (m *Manual) LoadQuotes() ([]security.Quote, error) {
+	f, err := os.Open(m.file)
+	if err != nil {
+		return nil, fmt.Errorf("opening file [%s]: %w", m.file, err)
+	}
+	defer f.Close()
+
+	var raw []rawQuote
+	if strings.ToLower(filepath.Ext(m.file)) == ".csv" {
+		raw, err = readCSV(f)
+	} else {
+		raw, err = readYAML(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading file [%s]: %w", m.file, err)
+	}
+
+	quotes, err := validate(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quotes in [%s]: %w", m.file, err)
+	}
+	return quotes, nil
+}
+
+func readCSV(r io.Reader) ([]rawQuote, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("empty file")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if h := strings.Join(header, ","); !strings.EqualFold(strings.TrimPrefix(h, "\ufeff"), "date,close") {
+		return nil, fmt.Errorf("invalid header '%s': expected 'date,close'", h)
+	}
+
+	raw := []rawQuote{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		raw = append(raw, rawQuote{line: line, Date: record[0], Close: record[1]})
+	}
+	return raw, nil
+}
+
+func readYAML(r io.Reader) ([]rawQuote, error) {
+	var nodes []yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&nodes); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	raw := []rawQuote{}
+	for _, node := range nodes {
+		q := rawQuote{line: node.Line}
+		if err := node.Decode(&q); err != nil {
+			return nil, fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		raw = append(raw, q)
+	}
+	return raw, nil
+}
+
+// validate parses the quotes, checking that the dates are valid, not in the future and
+// not repeated, and that the closes are positive numbers.
+func validate(raw []rawQuote) ([]security.Quote, error) {
+	var errs error
+	quotes := []security.Quote{}
+	lines := map[time.Time]int{}
+	tomorrow := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+
+	for _, r := range raw {
+		var lineErr error
+
+		date, err := time.Parse(dateFormat, strings.TrimSpace(r.Date))
+		switch {
+		case err != nil:
+			lineErr = errors.Join(lineErr, fmt.Errorf("line %d: invalid date '%s', expected YYYY-MM-DD", r.line, r.Date))
+		case !date.Before(tomorrow):
+			lineErr = errors.Join(lineErr, fmt.Errorf("line %d: date %s in the future", r.line, r.Date))
+		case lines[date] != 0:
+			lineErr = errors.Join(lineErr, fmt.Errorf("line %d: date %s already at line %d", r.line, r.Date, lines[date]))
+		default:
+			lines[date] = r.line
+		}
+
+		closeQuote, err := strconv.ParseFloat(strings.TrimSpace(r.Close), 32)
+		if err != nil || closeQuote <= 0 {
+			lineErr = errors.Join(lineErr, fmt.Errorf("line %d: invalid close '%s', expected a positive number", r.line, r.Close))
+		}
+
+		if lineErr != nil {
+			errs = errors.Join(errs, lineErr)
+			continue
+		}
+
+		quotes = append(quotes, security.Quote{
+			Date:  date,
+			Close: float32(closeQuote),
+		})
+	}
+	if errs != nil {
+		return nil, errs
+	}
+
+	sort.Slice(quotes, func(i, j int) bool {
+		return quotes[i].Date.Before(quotes[j].Date)
+	})
+
+	return quotes, nil
+}
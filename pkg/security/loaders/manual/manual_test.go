@@ -0,0 +1,201 @@
+package manual
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestLoadQuotes(t *testing.T) {
+	expected := []security.Quote{
+		{Date: day(2023, 12, 29), Close: 10.4},
+		{Date: day(2024, 1, 31), Close: 10.52},
+		{Date: day(2024, 2, 29), Close: 10.61},
+	}
+
+	for _, file := range []string{"testdata/valid.csv", "testdata/valid.yaml"} {
+		t.Run(file, func(t *testing.T) {
+			loader, err := New("Test", "IT0000000001", security.Params{"file": file})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			quotes, err := loader.LoadQuotes()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(quotes, expected) {
+				t.Errorf("unexpected quotes:\nexpected %v\ngot      %v", expected, quotes)
+			}
+		})
+	}
+}
+
+func TestLoadQuotesErrors(t *testing.T) {
+	tests := []struct {
+		file string
+		errs []string
+	}{
+		{
+			file: "testdata/invalid.csv",
+			errs: []string{
+				"line 3: invalid date '31/01/2024', expected YYYY-MM-DD",
+				"line 4: date 2999-01-01 in the future",
+				"line 5: date 2024-01-31 already at line 2",
+				"line 6: invalid close '0', expected a positive number",
+				"line 7: invalid close '-1', expected a positive number",
+				"line 8: invalid close 'n.d.', expected a positive number",
+			},
+		},
+		{
+			file: "testdata/invalid.yaml",
+			errs: []string{
+				"line 2: date 2024-01-31 already at line 1",
+				"line 3: invalid date '2024-13-01', expected YYYY-MM-DD",
+				"line 4: invalid close '-10.61', expected a positive number",
+			},
+		},
+		{file: "testdata/header.csv", errs: []string{"invalid header 'Date;Close': expected 'date,close'"}},
+		{file: "testdata/empty.csv", errs: []string{"empty file"}},
+		{file: "testdata/short.csv", errs: []string{"wrong number of fields"}},
+		{file: "testdata/nested.yaml", errs: []string{"line 1:"}},
+		{file: "testdata/missing.csv", errs: []string{"opening file [testdata/missing.csv]"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			loader, err := New("Test", "IT0000000001", security.Params{"file": tt.file})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = loader.LoadQuotes()
+			if err == nil {
+				t.Fatalf("expected the errors %q", tt.errs)
+			}
+			for _, expected := range tt.errs {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("expected error '%s', got:\n%s", expected, err)
+				}
+			}
+			// all the invalid lines are reported, one per line
+			if len(tt.errs) > 1 {
+				if lines := strings.Count(err.Error(), "\n") + 1; lines != len(tt.errs) {
+					t.Errorf("expected %d errors, got %d:\n%s", len(tt.errs), lines, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(dateFormat)
+	today := time.Now().UTC().Format(dateFormat)
+
+	tests := []struct {
+		name string
+		raw  []rawQuote
+		err  string
+	}{
+		{name: "today", raw: []rawQuote{{line: 2, Date: today, Close: "1"}}},
+		{name: "spaces", raw: []rawQuote{{line: 2, Date: " 2024-01-31 ", Close: " 10.5 "}}},
+		{name: "tomorrow", raw: []rawQuote{{line: 2, Date: tomorrow, Close: "1"}}, err: "line 2: date " + tomorrow + " in the future"},
+		{name: "missing date", raw: []rawQuote{{line: 3, Close: "1"}}, err: "line 3: invalid date ''"},
+		{name: "missing close", raw: []rawQuote{{line: 4, Date: "2024-01-31"}}, err: "line 4: invalid close ''"},
+		{
+			name: "invalid date and close on the same line",
+			raw:  []rawQuote{{line: 5, Date: "2024-02-30", Close: "abc"}},
+			err:  "line 5: invalid date '2024-02-30', expected YYYY-MM-DD\nline 5: invalid close 'abc'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quotes, err := validate(tt.raw)
+			if tt.err == "" {
+				if err != nil || len(quotes) != len(tt.raw) {
+					t.Errorf("expected %d quotes, got %v (%v)", len(tt.raw), quotes, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error '%s', got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestReadCSVLines(t *testing.T) {
+	f, err := os.Open("testdata/valid.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	raw, err := readCSV(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the comments and the empty lines are counted in the line numbers
+	expected := []rawQuote{
+		{line: 3, Date: "2024-02-29", Close: "10.61"},
+		{line: 4, Date: "2024-01-31", Close: "10.52"},
+		{line: 6, Date: "2023-12-29", Close: "10.4"},
+	}
+	if !reflect.DeepEqual(raw, expected) {
+		t.Errorf("expected %+v, got %+v", expected, raw)
+	}
+}
+
+func TestReadYAMLLines(t *testing.T) {
+	f, err := os.Open("testdata/valid.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	raw, err := readYAML(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []rawQuote{
+		{line: 2, Date: "2024-02-29", Close: "10.61"},
+		{line: 3, Date: "2024-01-31", Close: "10.52"},
+		{line: 5, Date: "2023-12-29", Close: "10.4"},
+	}
+	if !reflect.DeepEqual(raw, expected) {
+		t.Errorf("expected %+v, got %+v", expected, raw)
+	}
+}
+
+func TestNew(t *testing.T) {
+	loader, err := New("Test", "IT0000000001", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join("manual", "IT0000000001.csv"); loader.file != expected {
+		t.Errorf("expected the default file '%s', got '%s'", expected, loader.file)
+	}
+
+	for _, file := range []string{"quotes.YML", "quotes.yaml", "quotes.Csv"} {
+		if _, err := New("Test", "IT0000000001", security.Params{"file": file}); err != nil {
+			t.Errorf("[%s] unexpected error: %s", file, err)
+		}
+	}
+
+	_, err = New("Test", "IT0000000001", security.Params{"file": "quotes.json"})
+	if err == nil || !strings.Contains(err.Error(), "unsupported file 'quotes.json'") {
+		t.Errorf("expected an unsupported file error, got %v", err)
+	}
+}
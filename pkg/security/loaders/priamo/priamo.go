@@ -1,39 +1,101 @@
 package priamo
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/charmbracelet/log"
 	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/calendar"
 )
 
-const (
-	PriamoURL = "https://www.fondopriamo.it/grafici/tabella.php?c="
-)
+// PriamoURL is the URL of the quotes of a comparto, followed by its code
+var PriamoURL = "https://www.fondopriamo.it/grafici/tabella.php?c="
+
+// Registry keeps track of the comparti used by the securities, to avoid publishing the
+// same data for two different securities. It is owned by the caller creating the loaders.
+type Registry struct {
+	mu sync.Mutex
+	// codes and series are the securities using the codes and the fingerprints of the series
+	codes  map[string]string
+	series map[string]string
+}
 
-var priamoCodeMap = map[string]string{
-	"FP-Priamo-BilanciatoSviluppo":  "330",
-	"FP-Priamo-GarantitoProtezione": "331",
-	"FP-Priamo-BilanciatoPrudenza":  "330",
+func NewRegistry() *Registry {
+	return &Registry{
+		codes:  map[string]string{},
+		series: map[string]string{},
+	}
+}
+
+// addCode returns an error if the code is already used by another security
+func (r *Registry) addCode(isin, code string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if other, found := r.codes[code]; found && other != isin {
+		return fmt.Errorf("code '%s' already used by %s", code, other)
+	}
+	r.codes[code] = isin
+	return nil
+}
+
+// addSeries returns an error if the same series was already loaded by another security
+func (r *Registry) addSeries(isin string, quotes []security.Quote) error {
+	if len(quotes) == 0 {
+		return nil
+	}
+
+	hash := sha256.New()
+	for _, q := range quotes {
+		fmt.Fprintf(hash, "%s=%v;", q.Date.Format(time.DateOnly), q.Close)
+	}
+	fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if other, found := r.series[fingerprint]; found && other != isin {
+		return fmt.Errorf("series is a duplicate of the one of %s", other)
+	}
+	r.series[fingerprint] = isin
+	return nil
 }
 
 type Priamo struct {
-	name string
-	isin string
-	code string
+	name     string
+	isin     string
+	code     string
+	registry *Registry
 }
 
-func New(name, isin string) *Priamo {
-	return &Priamo{
-		name: name,
-		isin: isin,
-		code: priamoCodeMap[isin],
+// New returns the loader of the Priamo comparto with the code set in the 'code' param,
+// checking in the registry that the code and the loaded series are not used by other securities.
+// The available codes can be listed with the 'priamo-comparti' command.
+func New(name, isin string, params security.Params, registry *Registry) (*Priamo, error) {
+	code := params["code"]
+	if code == "" {
+		return nil, fmt.Errorf("missing 'code' param: list the codes of the comparti with the 'priamo-comparti' command")
+	}
+
+	if err := registry.addCode(isin, code); err != nil {
+		return nil, err
 	}
+
+	return &Priamo{
+		name:     name,
+		isin:     isin,
+		code:     code,
+		registry: registry,
+	}, nil
 }
 
 func (e *Priamo) Name() string {
@@ -51,34 +113,93 @@ type PriamoData struct {
 }
 
 func (f *Priamo) LoadQuotes() ([]security.Quote, error) {
-	resp, err := http.Get(PriamoURL + f.code)
+	quotes, err := loadCode(f.code)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := f.registry.addSeries(f.isin, quotes); err != nil {
+		return nil, err
+	}
+	return quotes, nil
+}
+
+// Comparto is a comparto found on the Priamo site.
+type Comparto struct {
+	Code      string
+	Quotes    int
+	FirstDate time.Time
+	LastDate  time.Time
+	LastClose float32
+}
+
+// Discover returns the comparti with quotes among the codes in the [from, to] range.
+func Discover(from, to int) ([]Comparto, error) {
+	comparti := []Comparto{}
+
+	for code := from; code <= to; code++ {
+		quotes, err := loadCode(strconv.Itoa(code))
+		if err != nil {
+			log.Debugf("no comparto found for code %d: %s", code, err)
+			continue
+		}
+		if len(quotes) == 0 {
+			continue
+		}
+
+		comparti = append(comparti, Comparto{
+			Code:      strconv.Itoa(code),
+			Quotes:    len(quotes),
+			FirstDate: quotes[0].Date,
+			LastDate:  quotes[len(quotes)-1].Date,
+			LastClose: quotes[len(quotes)-1].Close,
+		})
+	}
+
+	return comparti, nil
+}
+
+func loadCode(code string) ([]security.Quote, error) {
+	resp, err := http.Get(PriamoURL + code)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("error from request: status_code %d", resp.StatusCode)
+	}
+
 	var data []PriamoData
 	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
 		return nil, err
 	}
 
-	// reverse(years)
-
 	quotes := []security.Quote{}
 
 	for _, d := range data {
 		fields := strings.Fields(d.Data)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("invalid date '%s'", d.Data)
+		}
 
-		dateString := fmt.Sprintf("%s %s", d.Anno, convertMonth(fields[0]))
-		tt, err := time.Parse("2006 January", dateString)
+		year, err := strconv.Atoi(strings.TrimSpace(d.Anno))
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("invalid year '%s': %w", d.Anno, err)
+		}
+		month, found := convertMonth(fields[0])
+		if !found {
+			return nil, fmt.Errorf("invalid month '%s' for year %d", fields[0], year)
 		}
-		tt = tt.AddDate(0, 1, -1)
+		dateString := fmt.Sprintf("%s %d", month, year)
+
+		// the monthly NAV is valued on the last business day of the month
+		tt := calendar.TARGET2.LastBusinessDay(year, month)
 
 		value := strings.ReplaceAll(d.Valore, ",", ".")
 		closeQuote, err := strconv.ParseFloat(value, 32)
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("invalid value '%s' for %s: %w", d.Valore, dateString, err)
 		}
 
 		quotes = append(quotes, security.Quote{
@@ -94,32 +215,32 @@ func (f *Priamo) LoadQuotes() ([]security.Quote, error) {
 	return quotes, nil
 }
 
-func convertMonth(month string) time.Month {
+func convertMonth(month string) (time.Month, bool) {
 	switch month {
 	case "gennaio":
-		return time.January
+		return time.January, true
 	case "febbraio":
-		return time.February
+		return time.February, true
 	case "marzo":
-		return time.March
+		return time.March, true
 	case "aprile":
-		return time.April
+		return time.April, true
 	case "maggio":
-		return time.May
+		return time.May, true
 	case "giugno":
-		return time.June
+		return time.June, true
 	case "luglio":
-		return time.July
+		return time.July, true
 	case "agosto":
-		return time.August
+		return time.August, true
 	case "settembre":
-		return time.September
+		return time.September, true
 	case "ottobre":
-		return time.October
+		return time.October, true
 	case "novembre":
-		return time.November
+		return time.November, true
 	case "dicembre":
-		return time.December
+		return time.December, true
 	}
-	return time.January
+	return time.January, false
 }
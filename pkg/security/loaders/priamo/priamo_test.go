@@ -0,0 +1,169 @@
+package priamo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+)
+
+// newTestServer serves the 'testdata/<code>.json' files for the 'c' query param
+func newTestServer(t *testing.T) {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := os.ReadFile(filepath.Join("testdata", filepath.Base(r.URL.Query().Get("c"))+".json"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(ts.Close)
+
+	previousURL := PriamoURL
+	PriamoURL = ts.URL + "/tabella.php?c="
+	t.Cleanup(func() { PriamoURL = previousURL })
+}
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestLoadQuotes(t *testing.T) {
+	newTestServer(t)
+
+	loader, err := New("Test", "FP-Priamo-BilanciatoSviluppo", security.Params{"code": "330"}, NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quotes, err := loader.LoadQuotes()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// the monthly NAVs are valued on the last business day of the month
+	expected := []security.Quote{
+		{Date: day(2023, time.December, 29), Close: 10.512},
+		{Date: day(2024, time.January, 31), Close: 10.634},
+		{Date: day(2024, time.February, 29), Close: 10.701},
+	}
+	if !reflect.DeepEqual(quotes, expected) {
+		t.Errorf("unexpected quotes:\nexpected %v\ngot      %v", expected, quotes)
+	}
+}
+
+func TestLoadQuotesErrors(t *testing.T) {
+	newTestServer(t)
+
+	tests := []struct {
+		code string
+		err  string
+	}{
+		{code: "334", err: "invalid month 'january' for year 2024"},
+		{code: "999", err: "status_code 404"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			loader, err := New("Test", "FP-Priamo", security.Params{"code": tt.code}, NewRegistry())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = loader.LoadQuotes()
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error '%s', got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	registry := NewRegistry()
+
+	if _, err := New("Test", "FP-Priamo-BilanciatoPrudenza", security.Params{}, registry); err == nil || !strings.Contains(err.Error(), "missing 'code' param") {
+		t.Errorf("expected a missing code error, got %v", err)
+	}
+
+	if _, err := New("Test", "FP-Priamo-BilanciatoSviluppo", security.Params{"code": "330"}, registry); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// the same security can create other loaders of its code (i.e. the sources of the reconcile command)
+	if _, err := New("Test", "FP-Priamo-BilanciatoSviluppo", security.Params{"code": "330"}, registry); err != nil {
+		t.Errorf("unexpected error for the same security: %s", err)
+	}
+
+	_, err := New("Test", "FP-Priamo-BilanciatoPrudenza", security.Params{"code": "330"}, registry)
+	if err == nil || err.Error() != "code '330' already used by FP-Priamo-BilanciatoSviluppo" {
+		t.Errorf("expected a duplicate code error, got %v", err)
+	}
+
+	// the registries are independent
+	if _, err := New("Test", "FP-Priamo-BilanciatoPrudenza", security.Params{"code": "330"}, NewRegistry()); err != nil {
+		t.Errorf("unexpected error with another registry: %s", err)
+	}
+}
+
+// the comparti with different codes can serve the same series
+func TestLoadQuotesDuplicateSeries(t *testing.T) {
+	newTestServer(t)
+
+	registry := NewRegistry()
+
+	sviluppo, err := New("Test", "FP-Priamo-BilanciatoSviluppo", security.Params{"code": "330"}, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prudenza, err := New("Test", "FP-Priamo-BilanciatoPrudenza", security.Params{"code": "332"}, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	protezione, err := New("Test", "FP-Priamo-GarantitoProtezione", security.Params{"code": "331"}, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sviluppo.LoadQuotes(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// loading again the same security is not a duplicate
+	if _, err := sviluppo.LoadQuotes(); err != nil {
+		t.Fatalf("unexpected error loading again: %s", err)
+	}
+	if _, err := protezione.LoadQuotes(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, err = prudenza.LoadQuotes()
+	if err == nil || err.Error() != "series is a duplicate of the one of FP-Priamo-BilanciatoSviluppo" {
+		t.Errorf("expected a duplicate series error, got %v", err)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	newTestServer(t)
+
+	// 333 has no quotes, 334 is invalid and 335 is not found
+	comparti, err := Discover(329, 335)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Comparto{
+		{Code: "330", Quotes: 3, FirstDate: day(2023, time.December, 29), LastDate: day(2024, time.February, 29), LastClose: 10.701},
+		{Code: "331", Quotes: 2, FirstDate: day(2024, time.January, 31), LastDate: day(2024, time.February, 29), LastClose: 12.2},
+		{Code: "332", Quotes: 3, FirstDate: day(2023, time.December, 29), LastDate: day(2024, time.February, 29), LastClose: 10.701},
+	}
+	if !reflect.DeepEqual(comparti, expected) {
+		t.Errorf("unexpected comparti:\nexpected %+v\ngot      %+v", expected, comparti)
+	}
+}
@@ -10,30 +10,74 @@ import (
 	"time"
 
 	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/calendar"
 )
 
 const (
 	EPFCHFExchangeID = 3233
 	CHFCurrencyID    = 1
+
+	// backfillLimit is the oldest year requested during a backfill
+	backfillLimit = 1990
 )
 
 type RaiffeisenchQuoteLoader struct {
-	name string
-	isin string
+	name       string
+	isin       string
+	valor      int
+	exchangeID int
+	currencyID int
 }
 
-func New(name, isin string) *RaiffeisenchQuoteLoader {
-	return &RaiffeisenchQuoteLoader{
+// New returns the loader for the security. The optional params are:
+//   - exchangeId: the id of the exchange (default EPFCHFExchangeID)
+//   - currencyId: the id of the currency (default CHFCurrencyID)
+//   - valor: the valoren number, required for the non-Swiss ISINs
+func New(name, isin string, params security.Params) (*RaiffeisenchQuoteLoader, error) {
+	loader := &RaiffeisenchQuoteLoader{
 		name: name,
 		isin: isin,
 	}
+
+	var err error
+	if loader.exchangeID, err = intParam(params, "exchangeId", EPFCHFExchangeID); err != nil {
+		return nil, err
+	}
+	if loader.currencyID, err = intParam(params, "currencyId", CHFCurrencyID); err != nil {
+		return nil, err
+	}
+
+	if params["valor"] != "" {
+		loader.valor, err = intParam(params, "valor", 0)
+	} else {
+		// Translate ISIN to Valoren number
+		loader.valor, err = getValorFromISIN(isin)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return loader, nil
+}
+
+func intParam(params security.Params, key string, defaultValue int) (int, error) {
+	value, found := params[key]
+	if !found {
+		return defaultValue, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid '%s' param '%s': %w", key, value, err)
+	}
+	return i, nil
 }
 
 // getValorFromISIN returns the valoren number (https://en.wikipedia.org/wiki/Valoren_number) for a given ISIN (https://www.isin.org/isin-format/).
 // i.e. "CH0025417491" will return "2541749"
 func getValorFromISIN(isin string) (int, error) {
 	if !strings.HasPrefix(isin, "CH") {
-		return 0, fmt.Errorf("valor number can only be extracted for Swiss financial instruments")
+		return 0, fmt.Errorf("valor number can only be extracted for Swiss financial instruments, use the 'valor' param for %s", isin)
 	}
 
 	val := strings.TrimPrefix(isin, "CH")
@@ -74,19 +118,46 @@ type HistoryQuotesResponse struct {
 }
 
 func (r *RaiffeisenchQuoteLoader) LoadQuotes() ([]security.Quote, error) {
-	// Translate ISIN to Valoren number
-	valor, err := getValorFromISIN(r.isin)
-	if err != nil {
-		return nil, err
-	}
-
 	endDate := time.Now()
 	startDate := endDate.AddDate(-1, 0, 0) // one year ago
 
+	return r.loadRange(startDate, endDate)
+}
+
+// LoadQuotesSince loads the quotes from the since date to today.
+func (r *RaiffeisenchQuoteLoader) LoadQuotesSince(since time.Time) ([]security.Quote, error) {
+	return r.loadRange(since, time.Now())
+}
+
+// Backfill loads the full history of the security, walking the yearly windows
+// before the last year until no more quotes are returned.
+func (r *RaiffeisenchQuoteLoader) Backfill() ([]security.Quote, error) {
+	quotes := []security.Quote{}
+
+	endDate := time.Now()
+	for endDate.Year() >= backfillLimit {
+		startDate := endDate.AddDate(-1, 0, 0)
+
+		windowQuotes, err := r.loadRange(startDate, endDate)
+		if err != nil {
+			return nil, err
+		}
+		if len(windowQuotes) == 0 {
+			break
+		}
+
+		quotes = security.Merge(quotes, windowQuotes)
+		endDate = startDate
+	}
+
+	return quotes, nil
+}
+
+func (r *RaiffeisenchQuoteLoader) loadRange(startDate, endDate time.Time) ([]security.Quote, error) {
 	payload := HistoryQuotesRequest{
-		Valor:      valor,
-		ExchangeId: EPFCHFExchangeID,
-		CurrencyId: CHFCurrencyID,
+		Valor:      r.valor,
+		ExchangeId: r.exchangeID,
+		CurrencyId: r.currencyID,
 		From:       startDate.UTC(),
 		To:         endDate.UTC(),
 	}
@@ -116,6 +187,7 @@ func (r *RaiffeisenchQuoteLoader) LoadQuotes() ([]security.Quote, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error during get request: %w", err)
 	}
+	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("server replied with unexpected status code '%s'", res.Status)
@@ -130,12 +202,13 @@ func (r *RaiffeisenchQuoteLoader) LoadQuotes() ([]security.Quote, error) {
 	// Convert into the desired return format
 	quotes := make([]security.Quote, 0, len(result.HistoryQuotes))
 	for _, quote := range result.HistoryQuotes {
-		date, err := time.Parse("2006-01-02T15:04:05", quote.Date)
+		// the dates are in the Swiss local time
+		date, err := time.ParseInLocation("2006-01-02T15:04:05", quote.Date, calendar.SIX.Location)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse date '%s' of quote: %w", quote.Date, err)
 		}
 		quotes = append(quotes, security.Quote{
-			Date:  date,
+			Date:  calendar.SIX.Day(date),
 			Close: float32(quote.Close),
 		})
 	}
@@ -1,28 +1,73 @@
 package secondapensione
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/charmbracelet/log"
 	"github.com/enrichman/portfolio-perfomance/pkg/security"
 	"github.com/gocolly/colly/v2"
 )
 
 const (
-	SecondaPensioneUrlTemplate = "https://www.secondapensione.it/ezjscore/call/ezjscamundibuzz::sfForwardFront::paramsList=service=ProxyProductSheetV3Front&routeId=_en-GB_879_%s_tab_3"
+	SecondaPensioneUrlTemplate = "https://www.secondapensione.it/ezjscore/call/ezjscamundibuzz::sfForwardFront::paramsList=service=ProxyProductSheetV3Front&routeId=_en-GB_879_{isin}_tab_3"
+
+	defaultRangeFormat = "02/01/2006"
+	defaultRangeDays   = 365
+
+	// backfillLimit is the oldest year requested during a backfill
+	backfillLimit = 1990
 )
 
+// errNoRows is returned when the NAV table has no rows
+var errNoRows = errors.New("no rows in the '#tableVl' table")
+
 type SecondaPensione struct {
 	name string
 	isin string
+
+	// url is the template of the page, with the range placeholders
+	url         string
+	rangeFormat string
+	rangeDays   int
 }
 
-func New(name, isin string) *SecondaPensione {
-	return &SecondaPensione{
-		name: name,
-		isin: isin,
+// New returns the loader of the NAV history of the fund. The params are:
+//   - url: the URL of the page, where '{isin}' is replaced by the ISIN, and '{from}' and '{to}'
+//     by the range of the requested quotes (default the product sheet of the fund, without range)
+//   - rangeFormat: the Go layout of the range placeholders (default '02/01/2006')
+//   - rangeDays: the days of the range requested by a load, and of the backfill windows (default 365)
+func New(name, isin string, params security.Params) (*SecondaPensione, error) {
+	s := &SecondaPensione{
+		name:        name,
+		isin:        isin,
+		url:         params["url"],
+		rangeFormat: params["rangeFormat"],
+		rangeDays:   defaultRangeDays,
+	}
+
+	if s.url == "" {
+		s.url = SecondaPensioneUrlTemplate
+	}
+	s.url = strings.ReplaceAll(s.url, "{isin}", url.PathEscape(isin))
+
+	if s.rangeFormat == "" {
+		s.rangeFormat = defaultRangeFormat
+	}
+
+	if rangeDays := params["rangeDays"]; rangeDays != "" {
+		days, err := strconv.Atoi(rangeDays)
+		if err != nil || days <= 0 {
+			return nil, fmt.Errorf("invalid rangeDays '%s'", rangeDays)
+		}
+		s.rangeDays = days
 	}
+
+	return s, nil
 }
 
 func (e *SecondaPensione) Name() string {
@@ -33,16 +78,84 @@ func (e *SecondaPensione) ISIN() string {
 	return e.isin
 }
 
+// LoadQuotes loads the NAV history of the last 'rangeDays', or all the rows rendered in the
+// '#tableVl' table if the URL has no range placeholders.
 func (s *SecondaPensione) LoadQuotes() ([]security.Quote, error) {
+	to := time.Now().UTC()
+	return s.loadRange(to.AddDate(0, 0, -s.rangeDays), to)
+}
+
+// LoadQuotesSince loads the NAV history from the since date to today.
+func (s *SecondaPensione) LoadQuotesSince(since time.Time) ([]security.Quote, error) {
+	return s.loadRange(since, time.Now().UTC())
+}
+
+// Backfill loads the complete NAV history, walking the windows of 'rangeDays' back
+// until a window has no new rows. Without the range placeholders the page renders all the rows it has.
+func (s *SecondaPensione) Backfill() ([]security.Quote, error) {
+	if !s.hasRange() {
+		return s.LoadQuotes()
+	}
+
+	quotes := []security.Quote{}
+
+	to := time.Now().UTC()
+	for to.Year() >= backfillLimit {
+		from := to.AddDate(0, 0, -s.rangeDays)
+
+		windowQuotes, err := s.loadRange(from, to)
+		// the windows before the first NAV have no rows
+		if errors.Is(err, errNoRows) && len(quotes) > 0 {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		merged := security.Merge(quotes, windowQuotes)
+		// a page ignoring the range renders the same rows for every window
+		if len(merged) == len(quotes) {
+			break
+		}
+
+		quotes = merged
+		to = from
+	}
+
+	return quotes, nil
+}
+
+// hasRange returns true if the URL has the placeholders of the range
+func (s *SecondaPensione) hasRange() bool {
+	return strings.Contains(s.url, "{from}") || strings.Contains(s.url, "{to}")
+}
+
+// loadRange loads the rows of the page of the range. The missing table or rows are errors,
+// as they are a change of the layout of the page.
+func (s *SecondaPensione) loadRange(from, to time.Time) ([]security.Quote, error) {
 	c := colly.NewCollector()
 
-	url := fmt.Sprintf(SecondaPensioneUrlTemplate, s.isin)
+	pageURL := strings.NewReplacer(
+		"{from}", url.QueryEscape(from.Format(s.rangeFormat)),
+		"{to}", url.QueryEscape(to.Format(s.rangeFormat)),
+	).Replace(s.url)
 
 	quotes := []security.Quote{}
+	var parseErr error
+	var tableFound bool
+	var rows int
 
 	c.OnHTML("#tableVl", func(e *colly.HTMLElement) {
+		tableFound = true
+
 		e.ForEach("tbody tr", func(i int, e *colly.HTMLElement) {
-			dateString, valueString := parseRowText(e.ChildTexts("td"))
+			rows++
+
+			dateString, valueString, ok := parseRowText(e.ChildTexts("td"))
+			if !ok {
+				log.Debugf("skipping row %d of '%s': not enough cells", i, e.Request.URL)
+				return
+			}
 
 			if dateString == "" || valueString == "" {
 				return
@@ -50,12 +163,14 @@ func (s *SecondaPensione) LoadQuotes() ([]security.Quote, error) {
 
 			date, err := time.Parse("02/01/2006", dateString)
 			if err != nil {
-				panic(err)
+				parseErr = errors.Join(parseErr, fmt.Errorf("invalid date '%s': %w", dateString, err))
+				return
 			}
 
 			closeQuote, err := strconv.ParseFloat(valueString, 32)
 			if err != nil {
-				panic(err)
+				parseErr = errors.Join(parseErr, fmt.Errorf("invalid value '%s' for %s: %w", valueString, dateString, err))
+				return
 			}
 
 			quotes = append(quotes, security.Quote{
@@ -65,11 +180,27 @@ func (s *SecondaPensione) LoadQuotes() ([]security.Quote, error) {
 		})
 	})
 
-	c.Visit(url)
+	if err := c.Visit(pageURL); err != nil {
+		return nil, fmt.Errorf("error visiting '%s': %w", pageURL, err)
+	}
+	if !tableFound {
+		return nil, fmt.Errorf("table '#tableVl' not found in '%s'", pageURL)
+	}
+	if rows == 0 {
+		return nil, fmt.Errorf("%w of '%s'", errNoRows, pageURL)
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
 
-	return quotes, nil
+	// Merge removes the duplicated dates and sorts the quotes
+	return security.Merge(nil, quotes), nil
 }
 
-func parseRowText(values []string) (string, string) {
-	return values[0], values[1]
+// parseRowText returns the date and the value of the row, and false if the row is too short
+func parseRowText(values []string) (string, string, bool) {
+	if len(values) < 2 {
+		return "", "", false
+	}
+	return values[0], values[1], true
 }
@@ -0,0 +1,213 @@
+package secondapensione
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+// newRangeServer renders the '#tableVl' table with the quotes in the 'from' and 'to' range,
+// and records the requested ranges
+func newRangeServer(t *testing.T, quotes []security.Quote) (*httptest.Server, *[]string) {
+	t.Helper()
+
+	requested := []string{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		from, errFrom := time.Parse("02/01/2006", r.URL.Query().Get("from"))
+		to, errTo := time.Parse("02/01/2006", r.URL.Query().Get("to"))
+		if errFrom != nil || errTo != nil {
+			http.Error(w, "invalid range", http.StatusBadRequest)
+			return
+		}
+		requested = append(requested, r.URL.Query().Get("from")+"-"+r.URL.Query().Get("to"))
+
+		fmt.Fprint(w, `<html><body><table id="tableVl"><tbody>`)
+		for _, q := range quotes {
+			if !q.Date.Before(from) && !q.Date.After(to) {
+				fmt.Fprintf(w, "<tr><td>%s</td><td>%v</td></tr>", q.Date.Format("02/01/2006"), q.Close)
+			}
+		}
+		fmt.Fprint(w, `</tbody></table></body></html>`)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts, &requested
+}
+
+func TestLoadQuotes(t *testing.T) {
+	ts := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer ts.Close()
+
+	loader, err := New("Test", "QS0000003560", security.Params{"url": ts.URL + "/nav.html"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quotes, err := loader.LoadQuotes()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// the short rows are skipped, and the quotes sorted
+	expected := []security.Quote{
+		{Date: day(2024, 1, 2), Close: 10.45},
+		{Date: day(2024, 1, 3), Close: 10.471},
+		{Date: day(2024, 1, 4), Close: 10.498},
+		{Date: day(2024, 1, 5), Close: 10.512},
+	}
+	if !reflect.DeepEqual(quotes, expected) {
+		t.Errorf("unexpected quotes:\nexpected %v\ngot      %v", expected, quotes)
+	}
+
+	// without the range placeholders the backfill loads the rendered rows
+	backfilled, err := loader.Backfill()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(backfilled, expected) {
+		t.Errorf("unexpected backfill:\nexpected %v\ngot      %v", expected, backfilled)
+	}
+}
+
+func TestLoadQuotesErrors(t *testing.T) {
+	ts := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer ts.Close()
+
+	tests := []struct {
+		page string
+		err  string
+	}{
+		{page: "layout.html", err: "table '#tableVl' not found"},
+		{page: "empty.html", err: "no rows in the '#tableVl' table"},
+		{page: "invalid.html", err: "invalid value '10,512' for 05/01/2024"},
+		{page: "invalid.html", err: "invalid date '2024-01-04'"},
+		{page: "missing.html", err: "error visiting"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.page, func(t *testing.T) {
+			loader, err := New("Test", "QS0000003560", security.Params{"url": ts.URL + "/" + tt.page})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = loader.LoadQuotes()
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error '%s', got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestBackfill(t *testing.T) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	quotes := []security.Quote{
+		{Date: today.AddDate(0, 0, -700), Close: 9.5},
+		{Date: today.AddDate(0, 0, -400), Close: 10},
+		{Date: today.AddDate(0, 0, -10), Close: 10.5},
+	}
+	ts, requested := newRangeServer(t, quotes)
+
+	loader, err := New("Test", "QS0000003560", security.Params{
+		"url":       ts.URL + "/{isin}?from={from}&to={to}",
+		"rangeDays": "365",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backfilled, err := loader.Backfill()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(backfilled, quotes) {
+		t.Errorf("unexpected backfill:\nexpected %v\ngot      %v", quotes, backfilled)
+	}
+
+	// the windows are walked back until one has no rows
+	if len(*requested) != 3 {
+		t.Errorf("expected 3 windows, got %v", *requested)
+	}
+
+	// the loads request the last 'rangeDays'
+	loaded, err := loader.LoadQuotes()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(loaded, quotes[2:]) {
+		t.Errorf("unexpected quotes:\nexpected %v\ngot      %v", quotes[2:], loaded)
+	}
+
+	since, err := loader.LoadQuotesSince(today.AddDate(0, 0, -500))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(since, quotes[1:]) {
+		t.Errorf("unexpected quotes since:\nexpected %v\ngot      %v", quotes[1:], since)
+	}
+}
+
+// a page ignoring the range renders the same rows for every window
+func TestBackfillIgnoredRange(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.ServeFile(w, r, "testdata/nav.html")
+	}))
+	defer ts.Close()
+
+	loader, err := New("Test", "QS0000003560", security.Params{"url": ts.URL + "/nav?from={from}&to={to}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quotes, err := loader.Backfill()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(quotes) != 4 || requests != 2 {
+		t.Errorf("expected 4 quotes in 2 requests, got %d in %d", len(quotes), requests)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	for _, rangeDays := range []string{"0", "-1", "year"} {
+		_, err := New("Test", "QS0000003560", security.Params{"rangeDays": rangeDays})
+		if err == nil || !strings.Contains(err.Error(), "invalid rangeDays") {
+			t.Errorf("[%s] expected an invalid rangeDays error, got %v", rangeDays, err)
+		}
+	}
+}
+
+func TestParseRowText(t *testing.T) {
+	tests := []struct {
+		values []string
+		date   string
+		value  string
+		ok     bool
+	}{
+		{values: []string{"05/01/2024", "10.512"}, date: "05/01/2024", value: "10.512", ok: true},
+		{values: []string{"05/01/2024", "10.512", "+0.1%"}, date: "05/01/2024", value: "10.512", ok: true},
+		{values: []string{"05/01/2024"}},
+		{values: nil},
+	}
+
+	for _, tt := range tests {
+		date, value, ok := parseRowText(tt.values)
+		if date != tt.date || value != tt.value || ok != tt.ok {
+			t.Errorf("%v: expected (%s, %s, %v), got (%s, %s, %v)", tt.values, tt.date, tt.value, tt.ok, date, value, ok)
+		}
+	}
+}
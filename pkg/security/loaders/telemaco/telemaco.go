@@ -5,28 +5,81 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/log"
 	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/locale"
+	"github.com/gocolly/colly/v2"
+)
+
+var (
+	// defaultPageURL is the page searched for the CSV links, falling back to the CSV files
+	// of the comparti in fallbackURL when they are not found
+	defaultPageURL = "https://www.fondotelemaco.it/valori-quota/"
+	fallbackURL    = "https://www.fondotelemaco.it/grafici/csv/"
 )
 
 const (
-	urlPath = "https://www.fondotelemaco.it/grafici/csv/valori quota %s.csv"
+	defaultDateColumn  = "data"
+	defaultValueColumn = "valore"
 )
 
 type Telemaco struct {
-	name string
-	isin string
+	name     string
+	isin     string
+	comparto string
+	pageURL  string
+	// customPage is true if the page is set in the params, and the CSV link must be found in it
+	customPage  bool
+	dateColumn  string
+	valueColumn string
+	// customColumns is true if the columns are set in the params, and must be found in the header
+	customColumns bool
 }
 
-func New(name, isin string) *Telemaco {
-	return &Telemaco{
-		name: name,
-		isin: isin,
+// New returns the loader of the Telemaco comparto. The optional params are:
+//   - comparto: the name of the comparto in the CSV file name (default the last part of the ISIN, i.e. 'FP-Telemaco-dinamico')
+//   - page: the page with the links to the CSV files. Without it the CSV link is searched in the
+//     default page, falling back to the CSV file of the comparto
+//   - dateColumn, valueColumn: the headers of the date and value columns (default 'data' and 'valore',
+//     falling back to the first and second columns if not found)
+func New(name, isin string, params security.Params) (*Telemaco, error) {
+	t := &Telemaco{
+		name:        name,
+		isin:        isin,
+		comparto:    params["comparto"],
+		pageURL:     params["page"],
+		dateColumn:  strings.ToLower(params["dateColumn"]),
+		valueColumn: strings.ToLower(params["valueColumn"]),
+	}
+
+	if t.comparto == "" {
+		isinParts := strings.Split(isin, "-")
+		if len(isinParts) != 3 {
+			return nil, fmt.Errorf("invalid ISIN format for telemaco loader: expected 3 parts separated by '-', e.g. 'FP-Telemaco-dinamico', got %d", len(isinParts))
+		}
+		t.comparto = isinParts[2]
+	}
+
+	t.customPage = t.pageURL != ""
+	if !t.customPage {
+		t.pageURL = defaultPageURL
 	}
+
+	t.customColumns = t.dateColumn != "" || t.valueColumn != ""
+	if t.dateColumn == "" {
+		t.dateColumn = defaultDateColumn
+	}
+	if t.valueColumn == "" {
+		t.valueColumn = defaultValueColumn
+	}
+
+	return t, nil
 }
 
 func (e *Telemaco) Name() string { return e.name }
@@ -34,41 +87,68 @@ func (e *Telemaco) Name() string { return e.name }
 func (e *Telemaco) ISIN() string { return e.isin }
 
 func (t *Telemaco) LoadQuotes() ([]security.Quote, error) {
-	isinParts := strings.Split(t.isin, "-")
-	if len(isinParts) != 3 {
-		return nil, fmt.Errorf("invalid ISIN format for telemaco loader: expected 3 parts separated by '-', e.g. 'FP-Telemaco-dinamico', got %d", len(isinParts))
+	csvURL, err := t.findCSV()
+	if err != nil && t.customPage {
+		return nil, err
+	}
+	if err != nil {
+		csvURL = fallbackURL + url.PathEscape(fmt.Sprintf("valori quota %s.csv", t.comparto))
+		log.Debugf("[%s] %s, using '%s'", t.isin, err, csvURL)
 	}
-	url := fmt.Sprintf(urlPath, isinParts[2])
 
-	resp, err := http.Get(url)
+	resp, err := http.Get(csvURL)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("error from request: status_code %d", resp.StatusCode)
+	}
+
 	reader := csv.NewReader(resp.Body)
 	reader.Comma = ';'
+	reader.FieldsPerRecord = -1
 
 	records, err := reader.ReadAll()
 	if err != nil && err != io.EOF {
 		return nil, err
 	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("empty CSV file '%s'", csvURL)
+	}
+
+	dateIndex, valueIndex, err := t.columns(records[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// the first row is skipped if it is the header
+	first := 1
+	if _, err := parseDate(records[0][dateIndex]); err == nil {
+		first = 0
+	}
 
 	quotes := []security.Quote{}
-	for i, record := range records {
-		if i == 0 || len(record) < 2 {
+	for i, record := range records[first:] {
+		row := i + first + 1
+
+		if len(record) <= max(dateIndex, valueIndex) {
+			log.Warnf("[%s] skipping row %d: expected at least %d columns, got %d", t.isin, row, max(dateIndex, valueIndex)+1, len(record))
 			continue
 		}
 
-		date, err := parseDate(record[0])
+		date, err := parseDate(record[dateIndex])
 		if err != nil {
-			return nil, err
+			log.Warnf("[%s] skipping row %d: %s", t.isin, row, err)
+			continue
 		}
 
-		value := strings.ReplaceAll(strings.TrimSpace(record[1]), ",", ".")
+		value := strings.ReplaceAll(strings.TrimSpace(record[valueIndex]), ",", ".")
 		closeQuote, err := strconv.ParseFloat(value, 32)
 		if err != nil {
-			return nil, err
+			log.Warnf("[%s] skipping row %d: invalid value '%s'", t.isin, row, record[valueIndex])
+			continue
 		}
 
 		quotes = append(quotes, security.Quote{
@@ -84,32 +164,75 @@ func (t *Telemaco) LoadQuotes() ([]security.Quote, error) {
 	return quotes, nil
 }
 
+// findCSV returns the URL of the CSV file of the comparto linked in the page
+func (t *Telemaco) findCSV() (string, error) {
+	c := colly.NewCollector()
+
+	var csvURL string
+	c.OnHTML(`a[href*=".csv"]`, func(e *colly.HTMLElement) {
+		href := e.Request.AbsoluteURL(e.Attr("href"))
+
+		name, err := url.PathUnescape(href)
+		if err != nil {
+			name = href
+		}
+
+		if csvURL == "" && strings.Contains(strings.ToLower(name), strings.ToLower(t.comparto)) {
+			csvURL = href
+		}
+	})
+
+	if err := c.Visit(t.pageURL); err != nil {
+		return "", fmt.Errorf("error visiting '%s': %w", t.pageURL, err)
+	}
+	if csvURL == "" {
+		return "", fmt.Errorf("no CSV link found for comparto '%s' in '%s'", t.comparto, t.pageURL)
+	}
+
+	// the links can contain unescaped spaces
+	return strings.ReplaceAll(csvURL, " ", "%20"), nil
+}
+
+// columns returns the indexes of the date and value columns from the header, or the first
+// and second columns if the default headers are not found
+func (t *Telemaco) columns(header []string) (int, int, error) {
+	dateIndex, valueIndex := -1, -1
+
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+
+		if dateIndex == -1 && strings.Contains(h, t.dateColumn) {
+			dateIndex = i
+		} else if valueIndex == -1 && strings.Contains(h, t.valueColumn) {
+			valueIndex = i
+		}
+	}
+
+	if dateIndex != -1 && valueIndex != -1 {
+		return dateIndex, valueIndex, nil
+	}
+
+	if t.customColumns || len(header) < 2 {
+		return 0, 0, fmt.Errorf("columns '%s' and '%s' not found in header %q", t.dateColumn, t.valueColumn, header)
+	}
+
+	log.Debugf("[%s] columns '%s' and '%s' not found in header %q, using the first two columns", t.isin, t.dateColumn, t.valueColumn, header)
+	return 0, 1, nil
+}
+
+// parseDate parses the dates with the Italian month abbreviations and a two or four digits year,
+// i.e. "02-gen-06" or "02-gen-2006"
 func parseDate(value string) (time.Time, error) {
 	parts := strings.Split(strings.TrimSpace(value), "-")
 	if len(parts) != 3 {
 		return time.Time{}, fmt.Errorf("invalid date format: %s", value)
 	}
 
-	month, ok := monthMap[strings.ToLower(parts[1])]
-	if !ok {
-		return time.Time{}, fmt.Errorf("invalid month: %s", parts[1])
+	switch len(parts[2]) {
+	case 2:
+		return locale.Italian.ParseDate("2-Jan-06", value)
+	case 4:
+		return locale.Italian.ParseDate("2-Jan-2006", value)
 	}
-
-	normalized := fmt.Sprintf("%s-%s-%s", parts[0], month, parts[2])
-	return time.Parse("02-Jan-06", normalized)
-}
-
-var monthMap = map[string]string{
-	"gen": "Jan",
-	"feb": "Feb",
-	"mar": "Mar",
-	"apr": "Apr",
-	"mag": "May",
-	"giu": "Jun",
-	"lug": "Jul",
-	"ago": "Aug",
-	"set": "Sep",
-	"ott": "Oct",
-	"nov": "Nov",
-	"dic": "Dec",
+	return time.Time{}, fmt.Errorf("invalid year: %s", parts[2])
 }
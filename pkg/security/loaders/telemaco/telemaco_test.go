@@ -0,0 +1,276 @@
+package telemaco
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+)
+
+// newTestServer serves the page and the CSV files of the testdata directory, and records the requested paths
+func newTestServer(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+
+	requested := []string{}
+	files := http.FileServer(http.Dir("testdata"))
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		files.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts, &requested
+}
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestFindCSV(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	tests := []struct {
+		comparto string
+		csvURL   string
+		err      string
+	}{
+		// the unescaped spaces of the links are escaped
+		{comparto: "dinamico", csvURL: ts.URL + "/grafici/csv/valori%20quota%20dinamico.csv"},
+		{comparto: "garantito", csvURL: ts.URL + "/grafici/csv/valori%20quota%20garantito.csv"},
+		{comparto: "Prudente", csvURL: ts.URL + "/grafici/csv/valori%20quota%20prudente.csv"},
+		// only the CSV links are searched
+		{comparto: "crescita", err: "no CSV link found for comparto 'crescita'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.comparto, func(t *testing.T) {
+			loader, err := New("Test", "FP-Telemaco-"+tt.comparto, security.Params{"page": ts.URL + "/valori-quota.html"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			csvURL, err := loader.findCSV()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("expected error '%s', got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if csvURL != tt.csvURL {
+				t.Errorf("expected '%s', got '%s'", tt.csvURL, csvURL)
+			}
+		})
+	}
+}
+
+func TestLoadQuotes(t *testing.T) {
+	ts, _ := newTestServer(t)
+	page := ts.URL + "/valori-quota.html"
+
+	tests := []struct {
+		name   string
+		isin   string
+		params security.Params
+		quotes []security.Quote
+	}{
+		{
+			// the invalid and short rows are skipped, and the BOM of the header removed
+			name: "header with the default columns",
+			isin: "FP-Telemaco-dinamico",
+			quotes: []security.Quote{
+				{Date: day(2006, 1, 2), Close: 10},
+				{Date: day(2006, 1, 3), Close: 10.012},
+				{Date: day(2023, 12, 5), Close: 21.345},
+			},
+		},
+		{
+			name: "header with the columns in another order",
+			isin: "FP-Telemaco-bilanciato",
+			quotes: []security.Quote{
+				{Date: day(2006, 1, 2), Close: 12.5},
+				{Date: day(2006, 1, 3), Close: 12.75},
+			},
+		},
+		{
+			name: "unknown header falls back to the first two columns",
+			isin: "FP-Telemaco-garantito",
+			quotes: []security.Quote{
+				{Date: day(2006, 1, 2), Close: 10},
+				{Date: day(2024, 2, 29), Close: 15.5},
+			},
+		},
+		{
+			name: "no header",
+			isin: "FP-Telemaco-prudente",
+			quotes: []security.Quote{
+				{Date: day(2006, 1, 2), Close: 10},
+				{Date: day(2006, 1, 3), Close: 10.1},
+			},
+		},
+		{
+			name:   "custom columns",
+			isin:   "FP-Telemaco-garantito",
+			params: security.Params{"dateColumn": "Giorno", "valueColumn": "quota"},
+			quotes: []security.Quote{
+				{Date: day(2006, 1, 2), Close: 10},
+				{Date: day(2024, 2, 29), Close: 15.5},
+			},
+		},
+		{
+			name:   "comparto param",
+			isin:   "FP-Telemaco",
+			params: security.Params{"comparto": "prudente"},
+			quotes: []security.Quote{
+				{Date: day(2006, 1, 2), Close: 10},
+				{Date: day(2006, 1, 3), Close: 10.1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := security.Params{"page": page}
+			for k, v := range tt.params {
+				params[k] = v
+			}
+
+			loader, err := New("Test", tt.isin, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			quotes, err := loader.LoadQuotes()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(quotes, tt.quotes) {
+				t.Errorf("unexpected quotes:\nexpected %v\ngot      %v", tt.quotes, quotes)
+			}
+		})
+	}
+}
+
+func TestLoadQuotesErrors(t *testing.T) {
+	ts, _ := newTestServer(t)
+	page := ts.URL + "/valori-quota.html"
+
+	tests := []struct {
+		name   string
+		isin   string
+		params security.Params
+		err    string
+	}{
+		{
+			name: "CSV not linked in the page",
+			isin: "FP-Telemaco-crescita",
+			err:  "no CSV link found for comparto 'crescita'",
+		},
+		{
+			name:   "page not found",
+			isin:   "FP-Telemaco-dinamico",
+			params: security.Params{"page": ts.URL + "/missing.html"},
+			err:    "error visiting",
+		},
+		{
+			name:   "custom columns not in the header",
+			isin:   "FP-Telemaco-dinamico",
+			params: security.Params{"dateColumn": "giorno"},
+			err:    "columns 'giorno' and 'valore' not found in header",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := security.Params{"page": page}
+			for k, v := range tt.params {
+				params[k] = v
+			}
+
+			loader, err := New("Test", tt.isin, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = loader.LoadQuotes()
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error '%s', got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// without the page param, the CSV file of the comparto is loaded if the default page has no link
+func TestLoadQuotesDefaultPage(t *testing.T) {
+	ts, requested := newTestServer(t)
+
+	previousPage, previousFallback := defaultPageURL, fallbackURL
+	defaultPageURL, fallbackURL = ts.URL+"/missing.html", ts.URL+"/grafici/csv/"
+	t.Cleanup(func() { defaultPageURL, fallbackURL = previousPage, previousFallback })
+
+	loader, err := New("Test", "FP-Telemaco-prudente", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	quotes, err := loader.LoadQuotes()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(quotes) != 2 {
+		t.Errorf("expected 2 quotes, got %v", quotes)
+	}
+
+	expected := []string{"/missing.html", "/grafici/csv/valori quota prudente.csv"}
+	if !reflect.DeepEqual(*requested, expected) {
+		t.Errorf("expected the requests %q, got %q", expected, *requested)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	_, err := New("Test", "FP-Telemaco", nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid ISIN format") {
+		t.Errorf("expected an invalid ISIN error, got %v", err)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		value string
+		date  time.Time
+		err   bool
+	}{
+		{value: "02-gen-06", date: day(2006, 1, 2)},
+		{value: "02-gen-2006", date: day(2006, 1, 2)},
+		{value: " 31-dic-23 ", date: day(2023, 12, 31)},
+		{value: "29-feb-2024", date: day(2024, 2, 29)},
+		{value: "02/01/2006", err: true},
+		{value: "02-xyz-06", err: true},
+		{value: "02-gen-006", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			date, err := parseDate(tt.value)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error, got %v", date)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !date.Equal(tt.date) {
+				t.Errorf("expected %v, got %v", tt.date, date)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package locale
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Locale has the month names and the number separators used by a source.
+type Locale struct {
+	Name string
+
+	// Months maps the lowercase full and abbreviated month names to the month
+	Months map[string]time.Month
+	// Decimal and Thousands are the separators of the numbers
+	Decimal   string
+	Thousands string
+}
+
+var (
+	English = &Locale{
+		Name:      "en",
+		Months:    months("january", "february", "march", "april", "may", "june", "july", "august", "september", "october", "november", "december"),
+		Decimal:   ".",
+		Thousands: ",",
+	}
+
+	Italian = &Locale{
+		Name:      "it",
+		Months:    months("gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"),
+		Decimal:   ",",
+		Thousands: ".",
+	}
+
+	German = &Locale{
+		Name:      "de",
+		Months:    months("januar", "februar", "märz", "april", "mai", "juni", "juli", "august", "september", "oktober", "november", "dezember"),
+		Decimal:   ",",
+		Thousands: ".",
+	}
+
+	French = &Locale{
+		Name:      "fr",
+		Months:    months("janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"),
+		Decimal:   ",",
+		Thousands: " ",
+	}
+
+	locales = map[string]*Locale{
+		English.Name: English,
+		Italian.Name: Italian,
+		German.Name:  German,
+		French.Name:  French,
+	}
+)
+
+// Get returns the locale with the name (i.e. "it").
+func Get(name string) (*Locale, error) {
+	l, found := locales[strings.ToLower(name)]
+	if !found {
+		return nil, fmt.Errorf("unknown locale '%s'", name)
+	}
+	return l, nil
+}
+
+// ParseDate parses the value with the Go layout, after replacing the localized month
+// names with the English ones. The layout must use "Jan" or "January" for the month names,
+// i.e. "02-gen-06" is parsed by the Italian locale with the "02-Jan-06" layout.
+func (l *Locale) ParseDate(layout, value string) (time.Time, error) {
+	full := strings.Contains(layout, "January")
+
+	var b strings.Builder
+	var word []rune
+
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		month, found := l.Months[strings.ToLower(string(word))]
+		switch {
+		case !found:
+			b.WriteString(string(word))
+		case full:
+			b.WriteString(month.String())
+		default:
+			b.WriteString(month.String()[:3])
+		}
+		word = word[:0]
+	}
+
+	for _, r := range strings.TrimSpace(value) {
+		if unicode.IsLetter(r) {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		b.WriteRune(r)
+	}
+	flush()
+
+	return time.Parse(layout, b.String())
+}
+
+// ParseFloat parses the number with the separators of the locale (i.e. "1.234,56" in Italian).
+func (l *Locale) ParseFloat(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if l.Thousands != "" {
+		value = strings.ReplaceAll(value, l.Thousands, "")
+	}
+	// the non-breaking spaces are used as thousands separators too
+	value = strings.ReplaceAll(value, "\u00a0", "")
+	value = strings.Replace(value, l.Decimal, ".", 1)
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number '%s'", value)
+	}
+	return f, nil
+}
+
+// months returns the full and the three letters names of the months, in calendar order.
+// The abbreviations shared by two months (i.e. "jui" in French) are left out.
+func months(names ...string) map[string]time.Month {
+	m := map[string]time.Month{}
+	ambiguous := map[string]bool{}
+
+	for i, name := range names {
+		month := time.Month(i + 1)
+		m[name] = month
+
+		abbr := []rune(name)
+		if len(abbr) <= 3 {
+			continue
+		}
+		short := string(abbr[:3])
+		if other, found := m[short]; found && other != month {
+			ambiguous[short] = true
+		}
+		m[short] = month
+	}
+
+	for short := range ambiguous {
+		delete(m, short)
+	}
+	return m
+}
@@ -0,0 +1,124 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+)
+
+// IndexEntry describes a published security in the 'index.json' and 'index.html' files.
+type IndexEntry struct {
+	ISIN     string `json:"isin"`
+	Listing  string `json:"listing,omitempty"`
+	Primary  bool   `json:"primary,omitempty"`
+	Name     string `json:"name"`
+	Loader   string `json:"loader"`
+	Currency string `json:"currency,omitempty"`
+	Group    string `json:"group,omitempty"`
+	Source   string `json:"source,omitempty"`
+	// Bond is the metadata parsed from the name of the bonds
+	Bond       *security.Bond `json:"bond,omitempty"`
+	FirstDate  *time.Time     `json:"firstDate,omitempty"`
+	LastDate   *time.Time     `json:"lastDate,omitempty"`
+	QuoteCount int            `json:"quoteCount"`
+	URL        string         `json:"url"`
+}
+
+// ID returns the identifier of the security of the entry, as Security.ID.
+func (e IndexEntry) ID() string {
+	if e.Listing != "" {
+		return e.ISIN + "." + e.Listing
+	}
+	return e.ISIN
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>portfolio-performance quotes</title>
+  <style>
+    body { font-family: sans-serif; }
+    table { border-collapse: collapse; }
+    th, td { padding: 4px 8px; border-bottom: 1px solid #ddd; text-align: left; }
+  </style>
+</head>
+<body>
+  <h1>portfolio-performance quotes</h1>
+  <p>{{ len . }} securities. Load them in Portfolio Performance with the <code>$[*].date</code> and <code>$[*].close</code> JSONPath expressions.</p>
+  <table>
+    <tr><th>Group</th><th>ISIN</th><th>Name</th><th>Currency</th><th>Bond</th><th>Loader</th><th>From</th><th>To</th><th>Quotes</th></tr>
+    {{- range . }}
+    <tr>
+      <td>{{ .Group }}</td>
+      <td><a href="{{ .URL }}">{{ .ISIN }}{{ with .Listing }}.{{ . }}{{ end }}</a>{{ if .Primary }} (primary){{ end }}</td>
+      <td>{{ .Name }}</td>
+      <td>{{ .Currency }}</td>
+      <td>{{ with .Bond }}{{ .String }}{{ end }}</td>
+      <td>{{ .Loader }}{{ with .Source }} ({{ . }}){{ end }}</td>
+      <td>{{ with .FirstDate }}{{ .Format "2006-01-02" }}{{ end }}</td>
+      <td>{{ with .LastDate }}{{ .Format "2006-01-02" }}{{ end }}</td>
+      <td>{{ .QuoteCount }}</td>
+    </tr>
+    {{- end }}
+  </table>
+</body>
+</html>
+`))
+
+// WriteIndex writes the 'index.json' and 'index.html' files, sorted by group, ISIN and listing.
+func WriteIndex(dir string, entries []IndexEntry) error {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Group != entries[j].Group {
+			return entries[i].Group < entries[j].Group
+		}
+		if entries[i].ISIN != entries[j].ISIN {
+			return entries[i].ISIN < entries[j].ISIN
+		}
+		return entries[i].Listing < entries[j].Listing
+	})
+
+	err := writeFile(filepath.Join(dir, "index.json"), func(w io.Writer) error {
+		jsonOutput, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshaling index: %w", err)
+		}
+		_, err = w.Write(jsonOutput)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeFile(filepath.Join(dir, "index.html"), func(w io.Writer) error {
+		return indexTemplate.Execute(w, entries)
+	})
+}
+
+// ReadIndex reads the entries of the 'index.json' file written by the previous run,
+// or none if the file does not exist.
+func ReadIndex(dir string) ([]IndexEntry, error) {
+	filename := filepath.Join(dir, "index.json")
+
+	indexBytes, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading file [%s]: %w", filename, err)
+	}
+
+	var entries []IndexEntry
+	if err := json.Unmarshal(indexBytes, &entries); err != nil {
+		return nil, fmt.Errorf("error unmarshaling file [%s]: %w", filename, err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,270 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+)
+
+const (
+	JSON     = "json"
+	CSV      = "csv"
+	JSONL    = "jsonl"
+	PPCSV    = "ppcsv"
+	MinJSON  = "min"
+	LastJSON = "last"
+	YearJSON = "yearly"
+)
+
+const defaultLastDays = 30
+
+const DateFormat = "2006-01-02"
+
+// Writer writes the merged quotes of a security in the out directory.
+type Writer interface {
+	Name() string
+	Write(dir, isin string, quotes []security.Quote) error
+}
+
+// New returns the Writer for the format, configured with the security params.
+func New(format string, params security.Params) (Writer, error) {
+	switch format {
+	case JSON:
+		return &JSONWriter{}, nil
+	case CSV:
+		return &CSVWriter{}, nil
+	case JSONL:
+		return &JSONLWriter{}, nil
+	case PPCSV:
+		return NewPPCSVWriter(params["decimal"])
+	case MinJSON:
+		return &MinJSONWriter{}, nil
+	case LastJSON:
+		return NewLastJSONWriter(params["lastDays"])
+	case YearJSON:
+		return &YearJSONWriter{}, nil
+	}
+	return nil, fmt.Errorf("unknown output format '%s'", format)
+}
+
+// JSONWriter writes the canonical pretty-printed 'json/<ISIN>.json' file.
+type JSONWriter struct{}
+
+func (j *JSONWriter) Name() string { return JSON }
+
+func (j *JSONWriter) Write(dir, isin string, quotes []security.Quote) error {
+	return writeFile(filepath.Join(dir, "json", isin+".json"), func(w io.Writer) error {
+		jsonOutput, err := json.MarshalIndent(quotes, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshaling quotes: %w", err)
+		}
+		_, err = w.Write(jsonOutput)
+		return err
+	})
+}
+
+// MinJSONWriter writes the minified 'json/<ISIN>.min.json' file.
+type MinJSONWriter struct{}
+
+func (m *MinJSONWriter) Name() string { return MinJSON }
+
+func (m *MinJSONWriter) Write(dir, isin string, quotes []security.Quote) error {
+	return writeJSON(filepath.Join(dir, "json", isin+".min.json"), quotes)
+}
+
+// LastJSONWriter writes the minified 'json/<ISIN>.last<N>.json' file, with only the
+// quotes of the last N days before the most recent one.
+type LastJSONWriter struct {
+	days int
+}
+
+func NewLastJSONWriter(days string) (*LastJSONWriter, error) {
+	if days == "" {
+		return &LastJSONWriter{days: defaultLastDays}, nil
+	}
+
+	n, err := strconv.Atoi(days)
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("invalid number of days '%s'", days)
+	}
+	return &LastJSONWriter{days: n}, nil
+}
+
+func (l *LastJSONWriter) Name() string { return LastJSON }
+
+func (l *LastJSONWriter) Write(dir, isin string, quotes []security.Quote) error {
+	lastQuotes := []security.Quote{}
+	if len(quotes) > 0 {
+		from := quotes[len(quotes)-1].Date.AddDate(0, 0, -l.days)
+		for _, q := range quotes {
+			if q.Date.After(from) {
+				lastQuotes = append(lastQuotes, q)
+			}
+		}
+	}
+
+	filename := filepath.Join(dir, "json", fmt.Sprintf("%s.last%d.json", isin, l.days))
+	return writeJSON(filename, lastQuotes)
+}
+
+// YearJSONWriter writes the minified 'json/<ISIN>/<YEAR>.json' partitions.
+type YearJSONWriter struct{}
+
+func (y *YearJSONWriter) Name() string { return YearJSON }
+
+func (y *YearJSONWriter) Write(dir, isin string, quotes []security.Quote) error {
+	years := []int{}
+	quotesByYear := map[int][]security.Quote{}
+	for _, q := range quotes {
+		year := q.Date.UTC().Year()
+		if _, found := quotesByYear[year]; !found {
+			years = append(years, year)
+		}
+		quotesByYear[year] = append(quotesByYear[year], q)
+	}
+
+	for _, year := range years {
+		filename := filepath.Join(dir, "json", isin, fmt.Sprintf("%d.json", year))
+		if err := writeJSON(filename, quotesByYear[year]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SeriesWriter writes the 'json/<ISIN>.<SERIES>.json' file of an additional series.
+type SeriesWriter struct {
+	series string
+}
+
+func NewSeriesWriter(series string) *SeriesWriter {
+	return &SeriesWriter{series: series}
+}
+
+func (s *SeriesWriter) Name() string { return s.series }
+
+func (s *SeriesWriter) Write(dir, isin string, quotes []security.Quote) error {
+	return writeFile(filepath.Join(dir, "json", fmt.Sprintf("%s.%s.json", isin, s.series)), func(w io.Writer) error {
+		jsonOutput, err := json.MarshalIndent(quotes, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshaling quotes: %w", err)
+		}
+		_, err = w.Write(jsonOutput)
+		return err
+	})
+}
+
+// CSVWriter writes the 'csv/<ISIN>.csv' file with a 'date,close' header.
+type CSVWriter struct{}
+
+func (c *CSVWriter) Name() string { return CSV }
+
+func (c *CSVWriter) Write(dir, isin string, quotes []security.Quote) error {
+	return writeFile(filepath.Join(dir, "csv", isin+".csv"), func(w io.Writer) error {
+		csvWriter := csv.NewWriter(w)
+		if err := csvWriter.Write([]string{"date", "close"}); err != nil {
+			return err
+		}
+
+		for _, q := range quotes {
+			err := csvWriter.Write([]string{
+				q.Date.UTC().Format(DateFormat),
+				strconv.FormatFloat(float64(q.Close), 'f', -1, 32),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		csvWriter.Flush()
+		return csvWriter.Error()
+	})
+}
+
+// JSONLWriter writes the 'jsonl/<ISIN>.jsonl' file, one quote per line.
+type JSONLWriter struct{}
+
+func (j *JSONLWriter) Name() string { return JSONL }
+
+func (j *JSONLWriter) Write(dir, isin string, quotes []security.Quote) error {
+	return writeFile(filepath.Join(dir, "jsonl", isin+".jsonl"), func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		for _, q := range quotes {
+			if err := encoder.Encode(q); err != nil {
+				return fmt.Errorf("error encoding quote: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// PPCSVWriter writes the 'ppcsv/<ISIN>.csv' file in the format expected by the
+// Portfolio Performance CSV import: a 'Date;Close' header and the locale decimal separator.
+type PPCSVWriter struct {
+	decimal string
+}
+
+func NewPPCSVWriter(decimal string) (*PPCSVWriter, error) {
+	if decimal == "" {
+		decimal = ","
+	}
+	if decimal != "," && decimal != "." {
+		return nil, fmt.Errorf("invalid decimal separator '%s'", decimal)
+	}
+	return &PPCSVWriter{decimal: decimal}, nil
+}
+
+func (p *PPCSVWriter) Name() string { return PPCSV }
+
+func (p *PPCSVWriter) Write(dir, isin string, quotes []security.Quote) error {
+	return writeFile(filepath.Join(dir, "ppcsv", isin+".csv"), func(w io.Writer) error {
+		if _, err := fmt.Fprintln(w, "Date;Close"); err != nil {
+			return err
+		}
+
+		for _, q := range quotes {
+			value := strconv.FormatFloat(float64(q.Close), 'f', -1, 32)
+			value = strings.Replace(value, ".", p.decimal, 1)
+
+			if _, err := fmt.Fprintf(w, "%s;%s\n", q.Date.UTC().Format(DateFormat), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func writeJSON(filename string, quotes []security.Quote) error {
+	return writeFile(filename, func(w io.Writer) error {
+		jsonOutput, err := json.Marshal(quotes)
+		if err != nil {
+			return fmt.Errorf("error marshaling quotes: %w", err)
+		}
+		_, err = w.Write(jsonOutput)
+		return err
+	})
+}
+
+func writeFile(filename string, write func(w io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return fmt.Errorf("error writing file [%s]: %w", filename, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return fmt.Errorf("error creating dir for file [%s]: %w", filename, err)
+	}
+
+	if err := os.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error writing to file [%s]: %w", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package security
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Params are the optional per-security settings read from the fourth column
+// of securities.csv, written as a query string (i.e. "formats=csv,jsonl&decimal=,").
+type Params map[string]string
+
+func ParseParams(s string) (Params, error) {
+	params := Params{}
+
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return params, nil
+	}
+
+	values, err := url.ParseQuery(s)
+	if err != nil {
+		return nil, fmt.Errorf("parsing params '%s': %w", s, err)
+	}
+
+	for k, v := range values {
+		params[k] = v[len(v)-1]
+	}
+	return params, nil
+}
+
+// List returns the comma separated values of the key, skipping the empty ones.
+func (p Params) List(key string) []string {
+	values := []string{}
+	for _, v := range strings.Split(p[key], ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -3,12 +3,14 @@ package security
 import (
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/log"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/calendar"
 )
 
-var Securities = make(map[string]QuoteLoader)
+var Securities = make(map[string]*Security)
 
 type QuoteLoader interface {
 	Name() string
@@ -16,35 +18,114 @@ type QuoteLoader interface {
 	LoadQuotes() ([]Quote, error)
 }
 
+// SinceLoader is a QuoteLoader that can load only the quotes after a date,
+// used to fetch the quotes missing from the stored history.
+type SinceLoader interface {
+	LoadQuotesSince(since time.Time) ([]Quote, error)
+}
+
+// Backfiller is a QuoteLoader that can load the full history of the security,
+// beyond the range returned by LoadQuotes.
+type Backfiller interface {
+	Backfill() ([]Quote, error)
+}
+
+// SeriesLoader is a QuoteLoader that can load additional series of the security,
+// at granularities other than the daily close (i.e. "weekly", "intraday").
+type SeriesLoader interface {
+	Series() []string
+	LoadSeries(series string) ([]Quote, error)
+}
+
+// SourceReporter is a QuoteLoader that loads the quotes from different sources,
+// reporting the ones that served the last load.
+type SourceReporter interface {
+	Sources() []string
+}
+
+// VenueLoader is a QuoteLoader of a listing of the security on a trading venue (i.e. "MOT").
+type VenueLoader interface {
+	Venue() string
+}
+
+// Security is a registered QuoteLoader with the settings read from securities.csv.
+type Security struct {
+	QuoteLoader
+
+	Loader string
+	Group  string
+	Params Params
+	// Key is the identifier of the security in securities.csv, with the venue of the
+	// loader if any (i.e. "IT0005532723.MOT")
+	Key string
+
+	// Listing is the venue of the security, set only for the ISINs listed on several venues
+	Listing string
+}
+
+// ID returns the identifier of the security in the registry, in the store and in the output
+// paths: the ISIN, or the ISIN and the venue of the listing (i.e. "IT0005547408.MOT").
+func (s *Security) ID() string {
+	if s.Listing != "" {
+		return s.ISIN() + "." + s.Listing
+	}
+	return s.ISIN()
+}
+
+// Bond returns the metadata parsed from the name of the security, if it is a bond.
+func (s *Security) Bond() (*Bond, bool) {
+	return ParseBondName(s.Name())
+}
+
+// IsPrimary returns true if the security is the primary listing of its ISIN, also
+// published with the plain ISIN.
+func (s *Security) IsPrimary() bool {
+	return s.Listing != "" && s.Params["primary"] == "true"
+}
+
+// Currency returns the 'currency' param of the security, or the currency of the bond
+// parsed from its name (i.e. "Btp Italia Mz28 Eur" will return "EUR").
+func (s *Security) Currency() string {
+	if currency := s.Params["currency"]; currency != "" {
+		return strings.ToUpper(currency)
+	}
+	if bond, ok := s.Bond(); ok {
+		return bond.Currency
+	}
+	return ""
+}
+
 type Quote struct {
 	Date  time.Time `json:"date"`
 	Close float32   `json:"close"`
 }
 
-func Register(fund QuoteLoader) {
-	isin := fund.ISIN()
-	if isin == "" {
+func Register(fund *Security) {
+	if fund.ISIN() == "" {
 		log.Fatal("security ISIN cannot be empty")
 	}
 
-	if _, found := Securities[isin]; found {
-		log.Fatal(fmt.Sprintf("security '%s' already registered", isin))
+	id := fund.ID()
+	if _, found := Securities[id]; found {
+		log.Fatal(fmt.Sprintf("security '%s' already registered", id))
 	}
 
-	Securities[isin] = fund
-	log.Info(fmt.Sprintf("security '%s' registered", isin))
+	Securities[id] = fund
+	log.Info(fmt.Sprintf("security '%s' registered", id))
 }
 
+// Merge merges the quotes, keeping one quote per calendar day: the ones in quotes2
+// replace the ones in quotes1 with the same day.
 func Merge(quotes1 []Quote, quotes2 []Quote) []Quote {
 	quotesMap := map[time.Time]Quote{}
 
 	for _, q := range quotes1 {
-		q.Date = q.Date.UTC()
+		q.Date = calendar.UTCDay(q.Date)
 		quotesMap[q.Date] = q
 	}
 
 	for _, q := range quotes2 {
-		q.Date = q.Date.UTC()
+		q.Date = calendar.UTCDay(q.Date)
 
 		if oldQuote, found := quotesMap[q.Date]; found {
 			if oldQuote.Close != q.Close {
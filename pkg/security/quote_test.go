@@ -0,0 +1,37 @@
+package security
+
+import "testing"
+
+type testLoader struct {
+	name, isin string
+}
+
+func (l testLoader) Name() string                 { return l.name }
+func (l testLoader) ISIN() string                 { return l.isin }
+func (l testLoader) LoadQuotes() ([]Quote, error) { return nil, nil }
+
+func TestSecurityCurrency(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   Params
+		currency string
+	}{
+		{name: "Swisscanto (CH) Index Equity Fund Switzerland Total (II) FA CHF", params: Params{"currency": "chf"}, currency: "CHF"},
+		{name: "Btp Tf 3,25% St46 Eur", params: Params{"currency": "EUR"}, currency: "EUR"},
+		// the param wins over the name
+		{name: "Isp Sc Jun36 Usd", params: Params{"currency": "EUR"}, currency: "EUR"},
+		// the bond parser is the fallback
+		{name: "Isp Sc Jun36 Usd", params: Params{}, currency: "USD"},
+		// the currency is not guessed from the names of the other securities
+		{name: "Swisscanto (CH) Index Equity Fund Emerging Markets Responsible FA CHF", params: Params{}, currency: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Security{QuoteLoader: testLoader{name: tt.name, isin: "IT0000000000"}, Params: tt.params}
+			if currency := s.Currency(); currency != tt.currency {
+				t.Errorf("expected '%s', got '%s'", tt.currency, currency)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	"github.com/enrichman/portfolio-perfomance/pkg/store"
+)
+
+const dateFormat = "2006-01-02"
+
+var (
+	ErrNotFound = errors.New("security not found")
+
+	validFields = map[string]bool{"date": true, "close": true}
+)
+
+// RefreshFunc loads the new quotes of the security and saves them in the served store.
+// It returns ErrNotFound if the security is not registered.
+type RefreshFunc func(isin string) error
+
+// Server serves the quotes of the securities saved in the quotes store.
+//
+//	GET  /quotes/{isin}?from=2024-01-01&to=2024-12-31&fields=date,close&format=json|csv
+//	POST /quotes/{isin}/refresh
+type Server struct {
+	store   store.Store
+	refresh RefreshFunc
+
+	// refreshMu avoids concurrent refreshes writing the same quotes
+	refreshMu sync.Mutex
+	mux       *http.ServeMux
+}
+
+func New(st store.Store, refresh RefreshFunc) *Server {
+	s := &Server{
+		store:   st,
+		refresh: refresh,
+		mux:     http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /quotes/{isin}", s.handleQuotes)
+	s.mux.HandleFunc("POST /quotes/{isin}/refresh", s.handleRefresh)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+type quotesQuery struct {
+	from   time.Time
+	to     time.Time
+	fields []string
+	format string
+}
+
+func parseQuotesQuery(r *http.Request) (quotesQuery, error) {
+	query := quotesQuery{
+		fields: []string{"date", "close"},
+		format: "json",
+	}
+
+	values := r.URL.Query()
+
+	if from := values.Get("from"); from != "" {
+		t, err := time.Parse(dateFormat, from)
+		if err != nil {
+			return query, fmt.Errorf("invalid 'from' date '%s'", from)
+		}
+		query.from = t
+	}
+
+	if to := values.Get("to"); to != "" {
+		t, err := time.Parse(dateFormat, to)
+		if err != nil {
+			return query, fmt.Errorf("invalid 'to' date '%s'", to)
+		}
+		// include all the quotes of the 'to' day
+		query.to = t.AddDate(0, 0, 1)
+	}
+
+	if fields := values.Get("fields"); fields != "" {
+		query.fields = []string{}
+		for _, field := range strings.Split(fields, ",") {
+			field = strings.TrimSpace(field)
+			if !validFields[field] {
+				return query, fmt.Errorf("invalid field '%s'", field)
+			}
+			query.fields = append(query.fields, field)
+		}
+	}
+
+	if format := values.Get("format"); format != "" {
+		if format != "json" && format != "csv" {
+			return query, fmt.Errorf("invalid format '%s'", format)
+		}
+		query.format = format
+	}
+
+	return query, nil
+}
+
+func (q quotesQuery) filter(quotes []security.Quote) []security.Quote {
+	filtered := []security.Quote{}
+	for _, quote := range quotes {
+		if !q.from.IsZero() && quote.Date.Before(q.from) {
+			continue
+		}
+		if !q.to.IsZero() && !quote.Date.Before(q.to) {
+			continue
+		}
+		filtered = append(filtered, quote)
+	}
+	return filtered
+}
+
+func (s *Server) handleQuotes(w http.ResponseWriter, r *http.Request) {
+	isin := r.PathValue("isin")
+
+	query, err := parseQuotesQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the IDs are ISINs with an optional venue, never paths
+	if isin != filepath.Base(isin) || strings.HasPrefix(isin, ".") {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	quotes, err := s.store.Load(isin)
+	if err != nil {
+		log.Errorf("error loading quotes of [%s]: %s", isin, err)
+		http.Error(w, "error reading quotes", http.StatusInternalServerError)
+		return
+	}
+	if len(quotes) == 0 {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	modTime, err := s.store.ModTime(isin)
+	if err != nil {
+		log.Errorf("error loading quotes of [%s]: %s", isin, err)
+		http.Error(w, "error reading quotes", http.StatusInternalServerError)
+		return
+	}
+
+	var body []byte
+	switch query.format {
+	case "csv":
+		body, err = encodeCSV(query.filter(quotes), query.fields)
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	default:
+		body, err = encodeJSON(query.filter(quotes), query.fields)
+		w.Header().Set("Content-Type", "application/json")
+	}
+	if err != nil {
+		log.Errorf("error encoding quotes of [%s]: %s", isin, err)
+		http.Error(w, "error encoding quotes", http.StatusInternalServerError)
+		return
+	}
+
+	hash := sha256.Sum256(body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(hash[:16])+`"`)
+
+	// ServeContent handles the If-None-Match and If-Modified-Since conditional requests,
+	// without a Last-Modified header if the store has no save time
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(body))
+}
+
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	isin := r.PathValue("isin")
+
+	s.refreshMu.Lock()
+	err := s.refresh(isin)
+	s.refreshMu.Unlock()
+
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Errorf("error refreshing [%s]: %s", isin, err)
+		http.Error(w, fmt.Sprintf("error refreshing quotes: %s", err), http.StatusBadGateway)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func encodeJSON(quotes []security.Quote, fields []string) ([]byte, error) {
+	out := make([]map[string]any, 0, len(quotes))
+	for _, q := range quotes {
+		m := map[string]any{}
+		for _, field := range fields {
+			switch field {
+			case "date":
+				m["date"] = q.Date
+			case "close":
+				m["close"] = q.Close
+			}
+		}
+		out = append(out, m)
+	}
+	return json.Marshal(out)
+}
+
+func encodeCSV(quotes []security.Quote, fields []string) ([]byte, error) {
+	var buf bytes.Buffer
+	csvWriter := csv.NewWriter(&buf)
+
+	if err := csvWriter.Write(fields); err != nil {
+		return nil, err
+	}
+
+	for _, q := range quotes {
+		record := []string{}
+		for _, field := range fields {
+			switch field {
+			case "date":
+				record = append(record, q.Date.UTC().Format(dateFormat))
+			case "close":
+				record = append(record, strconv.FormatFloat(float64(q.Close), 'f', -1, 32))
+			}
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	csvWriter.Flush()
+	return buf.Bytes(), csvWriter.Error()
+}
@@ -0,0 +1,357 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	"github.com/enrichman/portfolio-perfomance/pkg/store"
+)
+
+const testISIN = "IT0005532723"
+
+func newTestServer(t *testing.T, refresh RefreshFunc) (*httptest.Server, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "json"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	quotes := `[
+		{"date": "2024-01-02T00:00:00Z", "close": 100.5},
+		{"date": "2024-01-03T00:00:00Z", "close": 101},
+		{"date": "2024-01-04T00:00:00Z", "close": 99.75}
+	]`
+	if err := os.WriteFile(filepath.Join(dir, "json", testISIN+".json"), []byte(quotes), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if refresh == nil {
+		refresh = func(string) error { return nil }
+	}
+
+	ts := httptest.NewServer(New(store.NewJSONStore(dir), refresh))
+	t.Cleanup(ts.Close)
+	return ts, dir
+}
+
+func get(t *testing.T, url string, headers map[string]string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, string(body)
+}
+
+func TestGetQuotes(t *testing.T) {
+	ts, _ := newTestServer(t, nil)
+
+	tests := []struct {
+		name        string
+		query       string
+		status      int
+		contentType string
+		body        string
+	}{
+		{
+			name:        "all the quotes",
+			query:       "",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `[{"close":100.5,"date":"2024-01-02T00:00:00Z"},{"close":101,"date":"2024-01-03T00:00:00Z"},{"close":99.75,"date":"2024-01-04T00:00:00Z"}]`,
+		},
+		{
+			name:        "from and to are inclusive",
+			query:       "?from=2024-01-03&to=2024-01-04",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `[{"close":101,"date":"2024-01-03T00:00:00Z"},{"close":99.75,"date":"2024-01-04T00:00:00Z"}]`,
+		},
+		{
+			name:        "fields",
+			query:       "?to=2024-01-02&fields=close",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `[{"close":100.5}]`,
+		},
+		{
+			name:        "csv format",
+			query:       "?from=2024-01-03&format=csv",
+			status:      http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			body:        "date,close\n2024-01-03,101\n2024-01-04,99.75\n",
+		},
+		{
+			name:        "csv format with fields",
+			query:       "?format=csv&fields=close,date&from=2024-01-04",
+			status:      http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			body:        "close,date\n99.75,2024-01-04\n",
+		},
+		{
+			name:   "invalid from",
+			query:  "?from=02/01/2024",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid field",
+			query:  "?fields=date,open",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "invalid format",
+			query:  "?format=xml",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, body := get(t, ts.URL+"/quotes/"+testISIN+tt.query, nil)
+
+			if res.StatusCode != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, res.StatusCode, body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			if contentType := res.Header.Get("Content-Type"); contentType != tt.contentType {
+				t.Errorf("expected Content-Type '%s', got '%s'", tt.contentType, contentType)
+			}
+			if body != tt.body {
+				t.Errorf("unexpected body:\nexpected %s\ngot      %s", tt.body, body)
+			}
+		})
+	}
+}
+
+func TestGetQuotesNotFound(t *testing.T) {
+	ts, dir := newTestServer(t, nil)
+
+	// the files outside the json directory are not served
+	if err := os.WriteFile(filepath.Join(dir, "index.json"), []byte(`[]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, isin := range []string{"IT0000000000", "..%2Findex"} {
+		res, _ := get(t, ts.URL+"/quotes/"+isin, nil)
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("[%s] expected status 404, got %d", isin, res.StatusCode)
+		}
+	}
+}
+
+func TestGetQuotesETag(t *testing.T) {
+	ts, _ := newTestServer(t, nil)
+	url := ts.URL + "/quotes/" + testISIN
+
+	res, _ := get(t, url, nil)
+	etag := res.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("missing ETag")
+	}
+
+	res, body := get(t, url, map[string]string{"If-None-Match": etag})
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("expected status 304, got %d", res.StatusCode)
+	}
+	if body != "" {
+		t.Errorf("expected an empty body, got %s", body)
+	}
+
+	// the ETag depends on the query
+	res, _ = get(t, url+"?format=csv", map[string]string{"If-None-Match": etag})
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200 with a different format, got %d", res.StatusCode)
+	}
+	if res.Header.Get("ETag") == etag {
+		t.Errorf("expected a different ETag with a different format")
+	}
+
+	res, _ = get(t, url, map[string]string{"If-None-Match": `"stale"`})
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200 with a stale ETag, got %d", res.StatusCode)
+	}
+}
+
+func TestGetQuotesIfModifiedSince(t *testing.T) {
+	ts, dir := newTestServer(t, nil)
+	url := ts.URL + "/quotes/" + testISIN
+
+	modTime := time.Date(2024, 1, 5, 18, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "json", testISIN+".json"), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	res, _ := get(t, url, nil)
+	if lastModified := res.Header.Get("Last-Modified"); lastModified != modTime.Format(http.TimeFormat) {
+		t.Errorf("expected Last-Modified '%s', got '%s'", modTime.Format(http.TimeFormat), lastModified)
+	}
+
+	res, _ = get(t, url, map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)})
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("expected status 304, got %d", res.StatusCode)
+	}
+
+	before := modTime.Add(-time.Hour).Format(http.TimeFormat)
+	res, _ = get(t, url, map[string]string{"If-Modified-Since": before})
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200 for a file modified after the date, got %d", res.StatusCode)
+	}
+}
+
+// the quotes are served from the store, also when they are not rendered in the out directory
+func TestGetQuotesBoltStore(t *testing.T) {
+	st, err := store.OpenBoltStore(filepath.Join(t.TempDir(), "quotes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	quotes := []security.Quote{
+		{Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Close: 100.5},
+		{Date: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), Close: 101},
+	}
+	if err := st.Save("IT0005547408.MOT", quotes); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(New(st, func(string) error { return nil }))
+	defer ts.Close()
+
+	res, body := get(t, ts.URL+"/quotes/IT0005547408.MOT?format=csv", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", res.StatusCode, body)
+	}
+	if expected := "date,close\n2024-01-02,100.5\n2024-01-03,101\n"; body != expected {
+		t.Errorf("unexpected body:\nexpected %s\ngot      %s", expected, body)
+	}
+
+	lastModified := res.Header.Get("Last-Modified")
+	if lastModified == "" {
+		t.Fatal("missing Last-Modified")
+	}
+	res, _ = get(t, ts.URL+"/quotes/IT0005547408.MOT?format=csv", map[string]string{"If-Modified-Since": lastModified})
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("expected status 304, got %d", res.StatusCode)
+	}
+
+	res, _ = get(t, ts.URL+"/quotes/IT0005547408", nil)
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status 404 for a security not in the store, got %d", res.StatusCode)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	tests := []struct {
+		name    string
+		isin    string
+		err     error
+		status  int
+		message string
+	}{
+		{
+			name:   "success",
+			isin:   testISIN,
+			status: http.StatusNoContent,
+		},
+		{
+			name:    "unknown security",
+			isin:    "IT0000000000",
+			err:     ErrNotFound,
+			status:  http.StatusNotFound,
+			message: "security not found",
+		},
+		{
+			name:    "loader error",
+			isin:    testISIN,
+			err:     errors.New("status_code 500"),
+			status:  http.StatusBadGateway,
+			message: "error refreshing quotes: status_code 500",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var refreshed []string
+			ts, _ := newTestServer(t, func(isin string) error {
+				refreshed = append(refreshed, isin)
+				return tt.err
+			})
+
+			res, err := http.Post(ts.URL+"/quotes/"+tt.isin+"/refresh", "", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCode != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, res.StatusCode)
+			}
+			if strings.TrimSpace(string(body)) != tt.message {
+				t.Errorf("expected message '%s', got '%s'", tt.message, body)
+			}
+			if len(refreshed) != 1 || refreshed[0] != tt.isin {
+				t.Errorf("expected a refresh of [%s], got %v", tt.isin, refreshed)
+			}
+		})
+	}
+}
+
+func TestRefreshMethodNotAllowed(t *testing.T) {
+	ts, _ := newTestServer(t, func(string) error {
+		t.Error("unexpected refresh")
+		return nil
+	})
+
+	res, _ := get(t, ts.URL+"/quotes/"+testISIN+"/refresh", nil)
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405, got %d", res.StatusCode)
+	}
+}
+
+// the JSON bodies are valid JSON arrays also when no quotes are in the range
+func TestGetQuotesEmptyRange(t *testing.T) {
+	ts, _ := newTestServer(t, nil)
+
+	res, body := get(t, ts.URL+"/quotes/"+testISIN+"?from=2025-01-01", nil)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", res.StatusCode)
+	}
+
+	var quotes []map[string]any
+	if err := json.Unmarshal([]byte(body), &quotes); err != nil || len(quotes) != 0 {
+		t.Errorf("expected an empty array, got %s", body)
+	}
+}
@@ -0,0 +1,231 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	quotesBucket    = []byte("quotes")
+	revisionsBucket = []byte("revisions")
+	// savedBucket keeps the time of the last save of the securities
+	savedBucket = []byte("saved")
+)
+
+// BoltStore stores the quotes in an embedded bbolt database, with a bucket per security
+// keyed by date. The values that get replaced are kept in the revisions buckets.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// Revision is a stored quote replaced by a different value.
+type Revision struct {
+	security.Quote
+	ReplacedAt time.Time `json:"replacedAt"`
+}
+
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening database [%s]: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{quotesBucket, revisionsBucket, savedBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating buckets: %w", err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// dateKey returns the sortable key of the date
+func dateKey(t time.Time) []byte {
+	return []byte(t.UTC().Format(time.RFC3339))
+}
+
+func (b *BoltStore) Load(isin string) ([]security.Quote, error) {
+	return b.Range(isin, time.Time{}, time.Time{})
+}
+
+func (b *BoltStore) Range(isin string, from, to time.Time) ([]security.Quote, error) {
+	quotes := []security.Quote{}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(quotesBucket).Bucket([]byte(isin))
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+
+		k, v := c.First()
+		if !from.IsZero() {
+			k, v = c.Seek(dateKey(from))
+		}
+
+		for ; k != nil; k, v = c.Next() {
+			if !to.IsZero() && bytes.Compare(k, dateKey(to)) >= 0 {
+				break
+			}
+
+			var q security.Quote
+			if err := json.Unmarshal(v, &q); err != nil {
+				return fmt.Errorf("error unmarshaling quote '%s': %w", k, err)
+			}
+			quotes = append(quotes, q)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error loading quotes of [%s]: %w", isin, err)
+	}
+
+	return quotes, nil
+}
+
+func (b *BoltStore) Save(isin string, quotes []security.Quote) error {
+	now := time.Now().UTC()
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket(quotesBucket)
+
+		// keep the replaced values in the revisions bucket before replacing them
+		if old := root.Bucket([]byte(isin)); old != nil {
+			revisions, err := tx.Bucket(revisionsBucket).CreateBucketIfNotExists([]byte(isin))
+			if err != nil {
+				return err
+			}
+
+			for _, q := range quotes {
+				oldValue := old.Get(dateKey(q.Date))
+				if oldValue == nil {
+					continue
+				}
+
+				var oldQuote security.Quote
+				if err := json.Unmarshal(oldValue, &oldQuote); err != nil {
+					return err
+				}
+				if oldQuote.Close == q.Close {
+					continue
+				}
+
+				revision, err := json.Marshal(Revision{Quote: oldQuote, ReplacedAt: now})
+				if err != nil {
+					return err
+				}
+
+				key := append(dateKey(q.Date), []byte("|"+now.Format(time.RFC3339Nano))...)
+				if err := revisions.Put(key, revision); err != nil {
+					return err
+				}
+			}
+
+			if err := root.DeleteBucket([]byte(isin)); err != nil {
+				return err
+			}
+		}
+
+		bucket, err := root.CreateBucket([]byte(isin))
+		if err != nil {
+			return err
+		}
+
+		for _, q := range quotes {
+			value, err := json.Marshal(q)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(dateKey(q.Date), value); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(savedBucket).Put([]byte(isin), []byte(now.Format(time.RFC3339Nano)))
+	})
+	if err != nil {
+		return fmt.Errorf("error saving quotes of [%s]: %w", isin, err)
+	}
+
+	log.Debugf("saved %d quotes of [%s]", len(quotes), isin)
+	return nil
+}
+
+// Revisions returns the replaced values of the quotes of the security.
+func (b *BoltStore) Revisions(isin string) ([]Revision, error) {
+	revisions := []Revision{}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(revisionsBucket).Bucket([]byte(isin))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			var r Revision
+			if err := json.Unmarshal(v, &r); err != nil {
+				return fmt.Errorf("error unmarshaling revision '%s': %w", k, err)
+			}
+			revisions = append(revisions, r)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error loading revisions of [%s]: %w", isin, err)
+	}
+
+	return revisions, nil
+}
+
+func (b *BoltStore) List() ([]string, error) {
+	isins := []string{}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(quotesBucket).ForEachBucket(func(k []byte) error {
+			isins = append(isins, string(k))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing securities: %w", err)
+	}
+
+	return isins, nil
+}
+
+func (b *BoltStore) ModTime(isin string) (time.Time, error) {
+	var modTime time.Time
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(savedBucket).Get([]byte(isin))
+		if value == nil {
+			return nil
+		}
+
+		var err error
+		modTime, err = time.Parse(time.RFC3339Nano, string(value))
+		return err
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error reading the save time of [%s]: %w", isin, err)
+	}
+
+	return modTime, nil
+}
+
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	"github.com/enrichman/portfolio-perfomance/pkg/security/output"
+)
+
+// JSONStore stores the quotes in the canonical 'json/<ISIN>.json' files of the out directory.
+type JSONStore struct {
+	dir string
+}
+
+func NewJSONStore(dir string) *JSONStore {
+	return &JSONStore{dir: dir}
+}
+
+func (j *JSONStore) filename(isin string) string {
+	return filepath.Join(j.dir, "json", isin+".json")
+}
+
+func (j *JSONStore) Load(isin string) ([]security.Quote, error) {
+	filename := j.filename(isin)
+
+	quotesByte, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading file [%s]: %w", filename, err)
+	}
+
+	var quotes []security.Quote
+	err = json.Unmarshal(quotesByte, &quotes)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshaling file [%s]: %w", filename, err)
+	}
+
+	sort.Slice(quotes, func(i, j int) bool {
+		return quotes[i].Date.Before(quotes[j].Date)
+	})
+
+	return quotes, nil
+}
+
+func (j *JSONStore) Range(isin string, from, to time.Time) ([]security.Quote, error) {
+	quotes, err := j.Load(isin)
+	if err != nil {
+		return nil, err
+	}
+	return filterRange(quotes, from, to), nil
+}
+
+func (j *JSONStore) Save(isin string, quotes []security.Quote) error {
+	return (&output.JSONWriter{}).Write(j.dir, isin, quotes)
+}
+
+func (j *JSONStore) List() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(j.dir, "json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading dir: %w", err)
+	}
+
+	isins := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		// skip the other JSON outputs (i.e. '<ISIN>.min.json' or '<ISIN>.weekly.json'),
+		// keeping the listings with their uppercase venue (i.e. '<ISIN>.MOT.json')
+		id := strings.TrimSuffix(name, ".json")
+		if _, suffix, found := strings.Cut(id, "."); found && (strings.Contains(suffix, ".") || suffix != strings.ToUpper(suffix)) {
+			continue
+		}
+		isins = append(isins, id)
+	}
+	return isins, nil
+}
+
+func (j *JSONStore) ModTime(isin string) (time.Time, error) {
+	info, err := os.Stat(j.filename(isin))
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error reading file info: %w", err)
+	}
+	return info.ModTime(), nil
+}
+
+func (j *JSONStore) Close() error {
+	return nil
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+)
+
+// Store persists the merged quotes of the securities.
+type Store interface {
+	// Load returns all the stored quotes of the security, sorted by date.
+	Load(isin string) ([]security.Quote, error)
+	// Range returns the stored quotes of the security in the [from, to) range.
+	// A zero from or to leaves that side of the range open.
+	Range(isin string, from, to time.Time) ([]security.Quote, error)
+	// Save replaces the stored quotes of the security.
+	Save(isin string, quotes []security.Quote) error
+	// List returns the ISINs of all the stored securities.
+	List() ([]string, error)
+	// ModTime returns the time of the last save of the security, zero if unknown.
+	ModTime(isin string) (time.Time, error)
+	Close() error
+}
+
+// Open opens the store described by the spec: "json" (or "json:<dir>") for the
+// JSON files in the out directory, "bolt:<file>" for an embedded bbolt database.
+func Open(spec, outDir string) (Store, error) {
+	kind, path, _ := strings.Cut(spec, ":")
+
+	switch kind {
+	case "", "json":
+		if path == "" {
+			path = outDir
+		}
+		return NewJSONStore(path), nil
+	case "bolt":
+		if path == "" {
+			return nil, fmt.Errorf("missing database file in store '%s'", spec)
+		}
+		return OpenBoltStore(path)
+	}
+	return nil, fmt.Errorf("unknown store '%s'", spec)
+}
+
+// Copy copies all the quotes stored in src to dst.
+func Copy(dst, src Store) (int, error) {
+	isins, err := src.List()
+	if err != nil {
+		return 0, fmt.Errorf("error listing securities: %w", err)
+	}
+
+	for _, isin := range isins {
+		quotes, err := src.Load(isin)
+		if err != nil {
+			return 0, fmt.Errorf("error loading quotes of [%s]: %w", isin, err)
+		}
+
+		if err := dst.Save(isin, quotes); err != nil {
+			return 0, fmt.Errorf("error saving quotes of [%s]: %w", isin, err)
+		}
+	}
+	return len(isins), nil
+}
+
+func filterRange(quotes []security.Quote, from, to time.Time) []security.Quote {
+	filtered := []security.Quote{}
+	for _, q := range quotes {
+		if !from.IsZero() && q.Date.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !q.Date.Before(to) {
+			continue
+		}
+		filtered = append(filtered, q)
+	}
+	return filtered
+}
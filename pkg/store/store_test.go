@@ -0,0 +1,304 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+)
+
+func day(d int) time.Time {
+	return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+}
+
+// openStores returns an empty store of each kind
+func openStores(t *testing.T) map[string]Store {
+	t.Helper()
+
+	bolt, err := OpenBoltStore(filepath.Join(t.TempDir(), "quotes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bolt.Close() })
+
+	return map[string]Store{
+		"json": NewJSONStore(t.TempDir()),
+		"bolt": bolt,
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	quotes := map[string][]security.Quote{
+		"IT0005532723":                 {{Date: day(2), Close: 100.5}, {Date: day(3), Close: 101}, {Date: day(4), Close: 99.75}},
+		"IT0005547408.MOT":             {{Date: day(2), Close: 98.1}},
+		"IT0005547408.TLX":             {{Date: day(3), Close: 98.2}},
+		"FP-Priamo-BilanciatoSviluppo": {{Date: day(5), Close: 12.345}},
+	}
+
+	for kind, st := range openStores(t) {
+		t.Run(kind, func(t *testing.T) {
+			for id, q := range quotes {
+				if err := st.Save(id, q); err != nil {
+					t.Fatalf("[%s] unexpected error: %s", id, err)
+				}
+			}
+
+			for id, expected := range quotes {
+				loaded, err := st.Load(id)
+				if err != nil {
+					t.Fatalf("[%s] unexpected error: %s", id, err)
+				}
+				if !reflect.DeepEqual(loaded, expected) {
+					t.Errorf("[%s] expected %v, got %v", id, expected, loaded)
+				}
+			}
+
+			ranged, err := st.Range("IT0005532723", day(3), day(4))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := quotes["IT0005532723"][1:2]; !reflect.DeepEqual(ranged, expected) {
+				t.Errorf("expected the range %v, got %v", expected, ranged)
+			}
+
+			ranged, err = st.Range("IT0005532723", day(3), time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := quotes["IT0005532723"][1:]; !reflect.DeepEqual(ranged, expected) {
+				t.Errorf("expected the open range %v, got %v", expected, ranged)
+			}
+
+			// the unknown securities have no quotes, without errors
+			missing, err := st.Load("IT0000000000")
+			if err != nil || len(missing) != 0 {
+				t.Errorf("expected no quotes, got %v (%v)", missing, err)
+			}
+
+			ids, err := st.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(ids)
+			expectedIDs := []string{"FP-Priamo-BilanciatoSviluppo", "IT0005532723", "IT0005547408.MOT", "IT0005547408.TLX"}
+			if !reflect.DeepEqual(ids, expectedIDs) {
+				t.Errorf("expected the IDs %v, got %v", expectedIDs, ids)
+			}
+
+			modTime, err := st.ModTime("IT0005547408.MOT")
+			if err != nil || modTime.IsZero() {
+				t.Errorf("expected the save time, got %v (%v)", modTime, err)
+			}
+			modTime, err = st.ModTime("IT0000000000")
+			if err != nil || !modTime.IsZero() {
+				t.Errorf("expected no save time, got %v (%v)", modTime, err)
+			}
+		})
+	}
+}
+
+// the other JSON outputs in the out directory are not stored securities
+func TestJSONStoreList(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "json", "nested"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{
+		"IT0005532723.json",
+		"IT0005547408.MOT.json",
+		"FP-Telemaco-dinamico.json",
+		"IT0005532723.min.json",
+		"IT0005532723.last30.json",
+		"IT0005532723.weekly.json",
+		"IT0005547408.MOT.min.json",
+		"IT0005547408.MOT.weekly.json",
+		"IT0005532723.csv",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, "json", name), []byte(`[]`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids, err := NewJSONStore(dir).List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(ids)
+
+	expected := []string{"FP-Telemaco-dinamico", "IT0005532723", "IT0005547408.MOT"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected %v, got %v", expected, ids)
+	}
+
+	ids, err = NewJSONStore(filepath.Join(dir, "missing")).List()
+	if err != nil || len(ids) != 0 {
+		t.Errorf("expected no IDs in a missing directory, got %v (%v)", ids, err)
+	}
+}
+
+func TestBoltStoreRevisions(t *testing.T) {
+	st, err := OpenBoltStore(filepath.Join(t.TempDir(), "quotes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	id := "IT0005547408.MOT"
+
+	if err := st.Save(id, []security.Quote{{Date: day(2), Close: 100}, {Date: day(3), Close: 101}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first save has no revisions
+	revisions, err := st.Revisions(id)
+	if err != nil || len(revisions) != 0 {
+		t.Fatalf("expected no revisions, got %v (%v)", revisions, err)
+	}
+
+	// the unchanged and the new quotes are not revisions
+	updated := []security.Quote{{Date: day(2), Close: 100}, {Date: day(3), Close: 101.5}, {Date: day(4), Close: 102}}
+	if err := st.Save(id, updated); err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, err = st.Revisions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 1 {
+		t.Fatalf("expected 1 revision, got %v", revisions)
+	}
+	if revisions[0].Quote != (security.Quote{Date: day(3), Close: 101}) || revisions[0].ReplacedAt.IsZero() {
+		t.Errorf("expected the replaced quote of %v, got %+v", day(3), revisions[0])
+	}
+
+	loaded, err := st.Load(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, updated) {
+		t.Errorf("expected %v, got %v", updated, loaded)
+	}
+
+	// the revisions are kept per security
+	revisions, err = st.Revisions("IT0005547408.TLX")
+	if err != nil || len(revisions) != 0 {
+		t.Errorf("expected no revisions of another listing, got %v (%v)", revisions, err)
+	}
+}
+
+// the revisions and the quotes survive the reopening of the database
+func TestBoltStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotes.db")
+
+	st, err := OpenBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Save("IT0005532723", []security.Quote{{Date: day(2), Close: 100}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Save("IT0005532723", []security.Quote{{Date: day(2), Close: 99}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err = OpenBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	loaded, err := st.Load("IT0005532723")
+	if err != nil || len(loaded) != 1 || loaded[0].Close != 99 {
+		t.Errorf("expected the saved quote, got %v (%v)", loaded, err)
+	}
+	revisions, err := st.Revisions("IT0005532723")
+	if err != nil || len(revisions) != 1 || revisions[0].Close != 100 {
+		t.Errorf("expected the revision, got %v (%v)", revisions, err)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	stores := openStores(t)
+	src, dst := stores["json"], stores["bolt"]
+
+	quotes := map[string][]security.Quote{
+		"IT0005532723":     {{Date: day(2), Close: 100.5}, {Date: day(3), Close: 101}},
+		"IT0005547408.MOT": {{Date: day(2), Close: 98.1}},
+	}
+	for id, q := range quotes {
+		if err := src.Save(id, q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := Copy(dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(quotes) {
+		t.Errorf("expected %d copied securities, got %d", len(quotes), n)
+	}
+
+	for id, expected := range quotes {
+		loaded, err := dst.Load(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(loaded, expected) {
+			t.Errorf("[%s] expected %v, got %v", id, expected, loaded)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		spec string
+		kind string
+		err  bool
+	}{
+		{spec: "", kind: "json"},
+		{spec: "json", kind: "json"},
+		{spec: "json:" + dir, kind: "json"},
+		{spec: "bolt:" + filepath.Join(dir, "quotes.db"), kind: "bolt"},
+		{spec: "bolt", err: true},
+		{spec: "sqlite:quotes.db", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			st, err := Open(tt.spec, dir)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer st.Close()
+
+			switch st.(type) {
+			case *JSONStore:
+				if tt.kind != "json" {
+					t.Errorf("expected a %s store, got a JSON store", tt.kind)
+				}
+			case *BoltStore:
+				if tt.kind != "bolt" {
+					t.Errorf("expected a %s store, got a bolt store", tt.kind)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/log"
+	"github.com/enrichman/portfolio-perfomance/pkg/reconcile"
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+)
+
+// reconcileCommand loads the quotes of the security from all its candidate sources, and reports
+// the differences of each source with the first one:
+//
+//	reconcile [-tolerance 0.5] [-sources fondidoc:IT0005217770] <ISIN>
+//
+// The security is its ISIN as published, or its key in securities.csv (i.e. "IT0005217770.MOT").
+// The candidates are the configured loader of the security followed by the -sources,
+// or the 'sources' of a fallback loader, loaded with the key of the security.
+func reconcileCommand(args []string) error {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	tolerance := flags.Float64("tolerance", 0.5, "maximum price difference, in percent")
+	sourcesFlag := flags.String("sources", "", "comma separated sources to compare, i.e. 'fondidoc:IT0005217770'")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: reconcile [-tolerance 0.5] [-sources <sources>] <ISIN>")
+	}
+
+	s, ok := findSecurity(flags.Arg(0))
+	if !ok {
+		return fmt.Errorf("security not found for ISIN %s", flags.Arg(0))
+	}
+	id := s.ID()
+
+	specs := security.Params{"sources": *sourcesFlag}.List("sources")
+	if s.Loader == "fallback" {
+		if len(specs) == 0 {
+			specs = s.Params.List("sources")
+		}
+	} else {
+		specs = append([]string{s.Loader}, specs...)
+	}
+	if len(specs) < 2 {
+		return fmt.Errorf("at least two sources are needed, set them with -sources")
+	}
+
+	series := []reconcile.Series{}
+	for _, spec := range specs {
+		// the sources are created as in securities.csv, with the venue of the key
+		source, err := newSource(spec, s.Name(), s.Key, s.Params)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("[%s] loading quotes from source '%s'", id, spec)
+		quotes, err := source.LoadQuotes()
+		if err != nil {
+			log.Errorf("[%s] source '%s' failed: %s", id, spec, err)
+			continue
+		}
+		series = append(series, reconcile.Series{Source: spec, Quotes: quotes})
+	}
+	if len(series) < 2 {
+		return fmt.Errorf("loaded %d of %d sources, nothing to compare", len(series), len(specs))
+	}
+
+	fmt.Printf("[%s] %s\n\n", id, s.Name())
+	for _, other := range series[1:] {
+		reconcile.Compare(series[0], other, *tolerance).Write(os.Stdout)
+		fmt.Println()
+	}
+	return nil
+}
+
+// findSecurity returns the security with the ID, or with the key in securities.csv
+func findSecurity(id string) (*security.Security, bool) {
+	if s, ok := security.Securities[id]; ok {
+		return s, true
+	}
+	for _, s := range security.Securities {
+		if s.Key == id {
+			return s, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+
+	"github.com/charmbracelet/log"
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	"github.com/enrichman/portfolio-perfomance/pkg/server"
+)
+
+// serve exposes the quotes of the QUOTES_STORE over HTTP
+func serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":8080", "address to listen on")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	srv := server.New(quoteStore, func(isin string) error {
+		loader, ok := security.Securities[isin]
+		if !ok {
+			return server.ErrNotFound
+		}
+		if err := loadQuote(isin, loader, fetchOptionsFromEnv()); err != nil {
+			return err
+		}
+		return writeIndex()
+	})
+
+	log.Infof("serving quotes on '%s'", *addr)
+	return http.ListenAndServe(*addr, srv)
+}
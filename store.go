@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/log"
+	"github.com/enrichman/portfolio-perfomance/pkg/security"
+	"github.com/enrichman/portfolio-perfomance/pkg/store"
+)
+
+// storeCommand manages the quotes stores:
+//
+//	store copy <from> <to>   copies all the quotes between two stores (i.e. "json" and "bolt:quotes.db")
+//	store render             renders the output files of the registered securities from the QUOTES_STORE
+func storeCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing subcommand: copy, render")
+	}
+
+	switch args[0] {
+	case "copy":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: store copy <from> <to>")
+		}
+		return copyStore(args[1], args[2])
+
+	case "render":
+		return renderStore()
+	}
+	return fmt.Errorf("unknown subcommand '%s'", args[0])
+}
+
+func copyStore(from, to string) error {
+	src, err := store.Open(from, outDir)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := store.Open(to, outDir)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	n, err := store.Copy(dst, src)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("copied %d securities from '%s' to '%s'", n, from, to)
+	return nil
+}
+
+func renderStore() error {
+	var err error
+	quoteStore, err = store.Open(os.Getenv("QUOTES_STORE"), outDir)
+	if err != nil {
+		return err
+	}
+	defer quoteStore.Close()
+
+	for isin, s := range security.Securities {
+		quotes, err := loadStoredQuotes(isin, s)
+		if err != nil {
+			return err
+		}
+		if len(quotes) == 0 {
+			log.Warnf("[%s] no stored quotes found", isin)
+			continue
+		}
+
+		if err := renderOutputs(isin, s, quotes); err != nil {
+			return fmt.Errorf("[%s] %w", isin, err)
+		}
+	}
+
+	return writeIndex()
+}